@@ -0,0 +1,174 @@
+package aura
+
+import (
+	"fmt"
+)
+
+// AggregateOpts makes a HistogramVec or a TimerVec also report the series aggregated over the label keys
+// which are not kept, e.g. the service-wide percentiles of the ones by uri. Every child keeps a Sketch of
+// its observations since the last collecting, the sketches are merged by the kept label values, so the
+// aggregated series reflect the observations of the last interval. They are named with AggregatedSuffix
+// to be told apart from the ones of the children, e.g. `http.service.agg.0.99`.
+type AggregateOpts struct {
+	// Keep is the label keys kept by every aggregation, e.g. {{"status"}, {}} reports the series by status
+	// and the overall one. The `endpoint` label is always kept since it identifies where the metric comes from.
+	Keep [][]string
+
+	// RelativeAccuracy of the sketches, DefaultSketchAccuracy will be used if it is zero.
+	RelativeAccuracy float64
+
+	// Export is called with every aggregated sketch on collecting if it is not nil, e.g. to serialize the sketch
+	// by Sketch.MarshalBinary and merge it with the ones of the other processes on the server side.
+	Export func(labels map[string]string, sketch *Sketch)
+}
+
+// AggregatedSuffix is appended to the fqName of the Vec to name the aggregated series.
+const AggregatedSuffix = ".agg"
+
+// aggregatedDesc returns the desc which the aggregated series of the Vec are reported with.
+func aggregatedDesc(desc *Desc) *Desc {
+	return &Desc{
+		fqName:    desc.fqName + AggregatedSuffix,
+		help:      desc.help,
+		labelKeys: desc.labelKeys,
+		step:      desc.step,
+	}
+}
+
+// aggregatedKeys returns the label keys kept by the aggregation with the `endpoint` label added.
+func aggregatedKeys(desc *Desc, keys []string) []string {
+	if !desc.IsKeyIn("endpoint") {
+		return keys
+	}
+	for _, k := range keys {
+		if k == "endpoint" {
+			return keys
+		}
+	}
+	return append(append([]string{}, keys...), "endpoint")
+}
+
+func (opts *AggregateOpts) accuracy() float64 {
+	if opts.RelativeAccuracy <= 0 || opts.RelativeAccuracy >= 1 {
+		return DefaultSketchAccuracy
+	}
+	return opts.RelativeAccuracy
+}
+
+// newSketch returns the sketch of a child, nil if the aggregation is disabled.
+func (opts *AggregateOpts) newSketch() *Sketch {
+	if opts == nil {
+		return nil
+	}
+	return MustNewSketch(opts.accuracy())
+}
+
+// checkAggregateOpts validates the kept label keys of the Vec and records the error in the desc.
+func checkAggregateOpts(desc *Desc, opts *AggregateOpts) {
+	if opts == nil || desc.err != nil {
+		return
+	}
+
+	if err := validateAggregateOpts(desc, opts); err != nil {
+		desc.err = fmt.Errorf("%s: %v", desc.fqName, err)
+	}
+}
+
+func validateAggregateOpts(desc *Desc, opts *AggregateOpts) error {
+	if opts.RelativeAccuracy < 0 || opts.RelativeAccuracy >= 1 {
+		return fmt.Errorf("relative accuracy %v should be in (0, 1)", opts.RelativeAccuracy)
+	}
+
+	for _, keys := range opts.Keep {
+		if len(aggregatedKeys(desc, keys)) >= len(desc.labelKeys) {
+			return fmt.Errorf("aggregation %v should drop at least one label key except endpoint", keys)
+		}
+
+		seen := make(map[string]bool, len(keys))
+		for _, k := range keys {
+			if !desc.IsKeyIn(k) {
+				return fmt.Errorf("aggregation %v: unknown label key %s", keys, k)
+			}
+			if seen[k] {
+				return fmt.Errorf("aggregation %v: duplicate label key %s", keys, k)
+			}
+			seen[k] = true
+		}
+	}
+	return nil
+}
+
+// sketchedChild is the labels of a child and the sketch taken from it.
+type sketchedChild struct {
+	labels map[string]string
+	sketch *Sketch
+}
+
+// takeSketches takes the sketches of all the children, the sketches of the children are cleared.
+func (v *metricVec) takeSketches() []sketchedChild {
+	var ret []sketchedChild
+	for _, c := range v.snapshot() {
+		if s, ok := c.(interface{ takeSketch() sketchedChild }); ok {
+			ret = append(ret, s.takeSketch())
+		}
+	}
+	return ret
+}
+
+// aggregate merges the sketches of the children by the label values kept by every aggregation,
+// the merged sketches are exported if the Export is set.
+func aggregate(desc *Desc, opts *AggregateOpts, children []sketchedChild) []sketchedChild {
+	var ret []sketchedChild
+	for _, keep := range opts.Keep {
+		keys := aggregatedKeys(desc, keep)
+		groups := map[string]int{}
+		for _, c := range children {
+			lvs := make([]string, len(keys))
+			for i, k := range keys {
+				lvs[i] = c.labels[k]
+			}
+
+			lbp := makeLabelPairs("", keys, lvs)
+			idx, ok := groups[lbp]
+			if !ok {
+				idx = len(ret)
+				groups[lbp] = idx
+				ret = append(ret, sketchedChild{labels: makeLabelMap(keys, lvs), sketch: MustNewSketch(opts.accuracy())})
+			}
+			// the sketches of the children have the same accuracy, so merging never fails.
+			_ = ret[idx].sketch.Merge(c.sketch)
+		}
+	}
+
+	if opts.Export != nil {
+		for _, a := range ret {
+			opts.Export(a.labels, a.sketch)
+		}
+	}
+	return ret
+}
+
+// sketchDistribution adapts a Sketch to the distribution reported by the histograms and the timers.
+type sketchDistribution struct {
+	*Sketch
+}
+
+func (d sketchDistribution) Count() int64 {
+	return int64(d.Sketch.Count())
+}
+
+func (d sketchDistribution) Max() int64 {
+	return int64(d.Sketch.Max())
+}
+
+func (d sketchDistribution) Min() int64 {
+	return int64(d.Sketch.Min())
+}
+
+func (d sketchDistribution) Percentile(p float64) float64 {
+	return d.Sketch.Quantile(p)
+}
+
+func (d sketchDistribution) Sum() int64 {
+	return int64(d.Sketch.Sum())
+}
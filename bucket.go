@@ -0,0 +1,304 @@
+package aura
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// BucketLabel is the label of the upper inclusive bound of a bucket.
+const BucketLabel = "le"
+
+// BucketHistogram counts the observations in the buckets with the user-defined upper bounds.
+// Unlike the sampled Histogram, the cumulative bucket counts of different hosts can be summed up,
+// so the percentiles can be calculated correctly on the server side.
+type BucketHistogram interface {
+	Collector
+
+	Observe(float64)
+}
+
+type BucketHistogramOpts struct {
+	// Buckets are the upper inclusive bounds in increasing order, the +Inf bucket is always appended.
+	Buckets []float64
+
+	// VecOpts only works for the BucketHistogramVec.
+	VecOpts
+}
+
+var (
+	DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	DefaultBucketHistogramOpts = &BucketHistogramOpts{
+		Buckets: DefaultBuckets,
+	}
+)
+
+// LinearBuckets returns count buckets, each width wide, where the lowest bucket has an upper bound of start.
+func LinearBuckets(start, width float64, count int) []float64 {
+	if count < 1 {
+		panic("LinearBuckets needs a positive count")
+	}
+
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start += width
+	}
+	return buckets
+}
+
+// ExponentialBuckets returns count buckets, where the lowest bucket has an upper bound of start
+// and each following bucket's upper bound is factor times the previous one.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	if count < 1 {
+		panic("ExponentialBuckets needs a positive count")
+	}
+	if start <= 0 {
+		panic("ExponentialBuckets needs a positive start value")
+	}
+	if factor <= 1 {
+		panic("ExponentialBuckets needs a factor greater than 1")
+	}
+
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// checkBuckets validates the buckets and returns the upper bounds with +Inf appended.
+func checkBuckets(buckets []float64) ([]float64, error) {
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return nil, fmt.Errorf("buckets should be in strictly increasing order")
+		}
+	}
+
+	bounds := append([]float64{}, buckets...)
+	if len(bounds) == 0 || !math.IsInf(bounds[len(bounds)-1], 1) {
+		bounds = append(bounds, math.Inf(1))
+	}
+	return bounds, nil
+}
+
+// newBucketHistogramDesc returns the Desc with the error of the buckets or the reserved label recorded.
+func newBucketHistogramDesc(fqName, help string, step uint32, labelKeys []string, buckets []float64) (*Desc, []float64) {
+	desc := NewDesc(fqName, help, step, labelKeys)
+	if desc.err != nil {
+		return desc, nil
+	}
+
+	if desc.IsKeyIn(BucketLabel) {
+		desc.err = fmt.Errorf("%s: label key %q is reserved for the buckets", fqName, BucketLabel)
+		return desc, nil
+	}
+
+	bounds, err := checkBuckets(buckets)
+	if err != nil {
+		desc.err = fmt.Errorf("%s: %v", fqName, err)
+	}
+	return desc, bounds
+}
+
+func formatBound(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type bucketHistogram struct {
+	*Desc
+	touched
+
+	bounds   []float64
+	counts   []uint64
+	sumBits  uint64
+	count    uint64
+	labels   map[string]string
+	interval time.Duration
+}
+
+func newBucketHistogram(desc *Desc, bounds []float64, labels map[string]string) *bucketHistogram {
+	return &bucketHistogram{
+		Desc:   desc,
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+		labels: labels,
+	}
+}
+
+// BucketHistogramVec is a Collector that bundles a set of BucketHistograms which have the same Desc
+// but different label values. It is safe for concurrent use.
+type BucketHistogramVec struct {
+	*metricVec
+
+	opts *BucketHistogramOpts
+}
+
+// Observe adds the observation to the bucket it belongs to, NaN and ±Inf are ignored.
+func (h *bucketHistogram) Observe(v float64) {
+	// NaN belongs to no bucket, and both NaN and ±Inf would poison the sum.
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+
+	idx := sort.SearchFloat64s(h.bounds, v)
+	if idx < len(h.counts) {
+		atomic.AddUint64(&h.counts[idx], 1)
+	}
+
+	for {
+		old := atomic.LoadUint64(&h.sumBits)
+		sum := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&h.sumBits, old, sum) {
+			break
+		}
+	}
+	atomic.AddUint64(&h.count, 1)
+	h.touch()
+}
+
+func (h *bucketHistogram) popMetric(desc *Desc, name string, value interface{}, labels map[string]string) Metric {
+	return Metric{
+		Endpoint:  labels["endpoint"],
+		Metric:    name,
+		Step:      desc.step,
+		Value:     value,
+		Type:      CounterValue,
+		Labels:    labels,
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// collect reports the cumulative count of every bucket, the sum and the count of the observations.
+func (h *bucketHistogram) collect(desc *Desc, ch chan<- Metric) {
+	bucketName := fmt.Sprintf("%s.bucket", desc.fqName)
+
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += atomic.LoadUint64(&h.counts[i])
+
+		labels := make(map[string]string, len(h.labels)+1)
+		for k, v := range h.labels {
+			labels[k] = v
+		}
+		labels[BucketLabel] = formatBound(bound)
+		ch <- h.popMetric(desc, bucketName, cumulative, labels)
+	}
+
+	sum := math.Float64frombits(atomic.LoadUint64(&h.sumBits))
+	ch <- h.popMetric(desc, fmt.Sprintf("%s.sum", desc.fqName), sum, h.labels)
+	ch <- h.popMetric(desc, fmt.Sprintf("%s.count", desc.fqName), atomic.LoadUint64(&h.count), h.labels)
+}
+
+// Interval implements aura.Collector.
+func (h *bucketHistogram) Interval() time.Duration {
+	return h.interval
+}
+
+// Describe implements aura.Collector.
+func (h *bucketHistogram) Describe(ch chan<- *Desc) {
+	ch <- h.Desc
+}
+
+// Collect implements aura.Collector.
+func (h *bucketHistogram) Collect(ch chan<- Metric) {
+	h.collect(h.Desc, ch)
+}
+
+// GetMetricWithLabelValues returns the histogram with the label values, the curried ones should not be given.
+// An error is returned if the number of label values is wrong or any value is invalid.
+func (hv *BucketHistogramVec) GetMetricWithLabelValues(lvs ...string) (BucketHistogram, error) {
+	lvs, err := hv.labelValues(lvs)
+	if err != nil {
+		return nil, err
+	}
+
+	return hv.searchBucketHistogram(lvs...), nil
+}
+
+// GetMetricWith returns the histogram with the labels, the missing label keys are regarded as empty values.
+// An error is returned if any label key is unknown or curried, or any value is invalid.
+func (hv *BucketHistogramVec) GetMetricWith(labels map[string]string) (BucketHistogram, error) {
+	lvs, err := hv.labelValuesFromMap(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return hv.searchBucketHistogram(lvs...), nil
+}
+
+// WithLabelValues works as GetMetricWithLabelValues but panics if an error occurs.
+func (hv *BucketHistogramVec) WithLabelValues(lvs ...string) BucketHistogram {
+	m, err := hv.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// With works as GetMetricWith but panics if an error occurs.
+func (hv *BucketHistogramVec) With(labels map[string]string) BucketHistogram {
+	m, err := hv.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (hv *BucketHistogramVec) searchBucketHistogram(lvs ...string) BucketHistogram {
+	return hv.child(lvs).(*bucketHistogram)
+}
+
+// CurryWith returns a BucketHistogramVec with the labels bound, the returned one shares the histograms with hv.
+// It returns an error if any label key is unknown or has been curried.
+func (hv *BucketHistogramVec) CurryWith(labels map[string]string) (*BucketHistogramVec, error) {
+	mv, err := hv.curryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &BucketHistogramVec{metricVec: mv, opts: hv.opts}, nil
+}
+
+// MustCurryWith is like CurryWith but panics if an error occurs.
+func (hv *BucketHistogramVec) MustCurryWith(labels map[string]string) *BucketHistogramVec {
+	curried, err := hv.CurryWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return curried
+}
+
+func NewBucketHistogram(fqName, help string, step uint32, interval time.Duration, opts *BucketHistogramOpts) BucketHistogram {
+	if opts == nil {
+		opts = DefaultBucketHistogramOpts
+	}
+
+	desc, bounds := newBucketHistogramDesc(fqName, help, step, nil, opts.Buckets)
+	h := newBucketHistogram(desc, bounds, map[string]string{})
+	h.interval = interval
+	return h
+}
+
+func NewBucketHistogramVec(fqName, help string, step uint32, interval time.Duration, labelKeys []string, opts *BucketHistogramOpts) *BucketHistogramVec {
+	if opts == nil {
+		opts = DefaultBucketHistogramOpts
+	}
+
+	desc, bounds := newBucketHistogramDesc(fqName, help, step, labelKeys, opts.Buckets)
+	return &BucketHistogramVec{
+		metricVec: newMetricVec("histogram", desc, interval, opts.VecOpts, func(labels map[string]string) vecChild {
+			h := newBucketHistogram(&Desc{step: step}, bounds, labels)
+			h.touched = newTouched(opts.TTL)
+			return h
+		}),
+		opts: opts,
+	}
+}
@@ -0,0 +1,249 @@
+package aura
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// OverflowLabelValue replaces the label values of the series beyond the cardinality limits.
+const OverflowLabelValue = "__overflow__"
+
+// cardinalityPruneInterval limits how often the stale series are pruned from the tracker.
+const cardinalityPruneInterval = time.Minute
+
+// overflowLabelValues returns the label values of the overflow series, the `endpoint` label
+// is kept since it identifies where the metric comes from rather than a dimension.
+func overflowLabelValues(keys, lvs []string) []string {
+	ret := make([]string, len(lvs))
+	for i, k := range keys {
+		if k == "endpoint" {
+			ret[i] = lvs[i]
+			continue
+		}
+		ret[i] = OverflowLabelValue
+	}
+	return ret
+}
+
+func overflowLabels(labels map[string]string) map[string]string {
+	ret := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if k == "endpoint" {
+			ret[k] = v
+			continue
+		}
+		ret[k] = OverflowLabelValue
+	}
+	return ret
+}
+
+// foldedSeries is a series folded into an overflow series, its last value is kept for the sum.
+type foldedSeries struct {
+	overflow string
+	value    float64
+	deadline int64
+}
+
+// cardinalityTracker counts the series of every metric name passing through the Registry,
+// and folds the new series into the overflow series once a metric reaches the limit.
+type cardinalityTracker struct {
+	max int
+
+	mtx       sync.Mutex
+	series    map[string]map[string]int64
+	folded    map[string]map[string]*foldedSeries
+	sums      map[string]map[string]float64
+	warned    map[string]bool
+	lastPrune time.Time
+}
+
+func newCardinalityTracker(max int) *cardinalityTracker {
+	return &cardinalityTracker{
+		max:       max,
+		series:    map[string]map[string]int64{},
+		folded:    map[string]map[string]*foldedSeries{},
+		sums:      map[string]map[string]float64{},
+		warned:    map[string]bool{},
+		lastPrune: time.Now(),
+	}
+}
+
+// staleDeadline returns the time after which the series is considered stale, zero means never.
+func staleDeadline(m *Metric) int64 {
+	if m.Step == 0 {
+		return 0
+	}
+	return m.Timestamp + int64(staleSteps*m.Step)
+}
+
+// prune removes the stale series of the metric, t.mtx must be held.
+func (t *cardinalityTracker) prune(metric string, now int64) {
+	for key, deadline := range t.series[metric] {
+		if deadline > 0 && now > deadline {
+			delete(t.series[metric], key)
+		}
+	}
+	if len(t.series[metric]) == 0 {
+		delete(t.series, metric)
+	}
+
+	for key, f := range t.folded[metric] {
+		if f.deadline > 0 && now > f.deadline {
+			t.sums[metric][f.overflow] -= f.value
+			delete(t.folded[metric], key)
+		}
+	}
+	if len(t.folded[metric]) == 0 {
+		delete(t.folded, metric)
+		delete(t.sums, metric)
+	}
+}
+
+// pruneAll removes all the stale series at most once per cardinalityPruneInterval, t.mtx must be held.
+func (t *cardinalityTracker) pruneAll() {
+	if time.Since(t.lastPrune) < cardinalityPruneInterval {
+		return
+	}
+
+	now := time.Now()
+	t.lastPrune = now
+	for metric := range t.series {
+		t.prune(metric, now.Unix())
+	}
+	for metric := range t.folded {
+		t.prune(metric, now.Unix())
+	}
+}
+
+// full reports whether a new series of the metric should be folded, the last slot is reserved
+// for the overflow series, t.mtx must be held.
+func (t *cardinalityTracker) full(metric string) bool {
+	return t.max > 0 && len(t.series[metric]) >= t.max-1
+}
+
+// track records the series of the metric and returns its series key. A new series beyond the limit
+// is folded into the overflow series: the labels of the metric are replaced by the overflow ones,
+// and the value by the sum of the last values of all the series folded into it.
+func (t *cardinalityTracker) track(m *Metric) string {
+	key := seriesKey(*m)
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.pruneAll()
+	if _, ok := t.folded[m.Metric][key]; ok {
+		return t.fold(m, key)
+	}
+
+	series, ok := t.series[m.Metric]
+	if !ok {
+		series = map[string]int64{}
+		t.series[m.Metric] = series
+	}
+
+	if _, ok := series[key]; !ok && t.full(m.Metric) {
+		t.prune(m.Metric, time.Now().Unix())
+		t.series[m.Metric] = series
+		if t.full(m.Metric) {
+			if !t.warned[m.Metric] {
+				t.warned[m.Metric] = true
+				log.Printf("aura: metric(%s) exceeds the limit of %d series, new series are folded into the %s series",
+					m.Metric, t.max, OverflowLabelValue,
+				)
+			}
+			return t.fold(m, key)
+		}
+	}
+
+	series[key] = staleDeadline(m)
+	return key
+}
+
+// fold folds the metric of the series key into its overflow series and returns the overflow key, t.mtx must be held.
+func (t *cardinalityTracker) fold(m *Metric, key string) string {
+	m.Labels = overflowLabels(m.Labels)
+	overflow := seriesKey(*m)
+
+	folded, ok := t.folded[m.Metric]
+	if !ok {
+		folded = map[string]*foldedSeries{}
+		t.folded[m.Metric] = folded
+	}
+
+	sums, ok := t.sums[m.Metric]
+	if !ok {
+		sums = map[string]float64{}
+		t.sums[m.Metric] = sums
+	}
+
+	f, ok := folded[key]
+	if !ok {
+		f = &foldedSeries{overflow: overflow}
+		folded[key] = f
+	}
+	f.deadline = staleDeadline(m)
+
+	// the values which are not numbers can not be summed, the last one is reported as it is.
+	if v, ok := toFloat64(m.Value); ok {
+		sums[overflow] += v - f.value
+		f.value = v
+		m.Value = sums[overflow]
+	}
+
+	if _, ok := t.series[m.Metric]; !ok {
+		t.series[m.Metric] = map[string]int64{}
+	}
+	t.series[m.Metric][overflow] = staleDeadline(m)
+	return overflow
+}
+
+// cardinality returns the number of series of every metric name.
+func (t *cardinalityTracker) cardinality() map[string]int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	now := time.Now().Unix()
+	ret := make(map[string]int, len(t.series))
+	for metric := range t.series {
+		t.prune(metric, now)
+		if n := len(t.series[metric]); n > 0 {
+			ret[metric] = n
+		}
+	}
+	return ret
+}
+
+// cardinalityCollector reports the cardinality of every metric name as a self-metric.
+type cardinalityCollector struct {
+	desc     *Desc
+	registry *Registry
+	interval time.Duration
+}
+
+// Interval implements aura.Collector.
+func (c *cardinalityCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Describe implements aura.Collector.
+func (c *cardinalityCollector) Describe(ch chan<- *Desc) {
+	ch <- c.desc
+}
+
+// Collect implements aura.Collector.
+func (c *cardinalityCollector) Collect(ch chan<- Metric) {
+	for metric, n := range c.registry.cardinality.cardinality() {
+		ch <- MustNewConstMetric(c.desc, GaugeValue, n, metric)
+	}
+}
+
+// NewCardinalityCollector returns a Collector which reports the number of series of every metric name
+// passing through the registry, labeled by `metric`. It should be registered to the same registry.
+func (r *Registry) NewCardinalityCollector(fqName string, step uint32, interval time.Duration) Collector {
+	return &cardinalityCollector{
+		desc:     NewDesc(fqName, "number of series of every metric", step, []string{"metric"}),
+		registry: r,
+		interval: interval,
+	}
+}
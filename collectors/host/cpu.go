@@ -0,0 +1,95 @@
+package host
+
+import (
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/shirou/gopsutil/cpu"
+)
+
+// CPUCollector collects the CPU utilization per mode in percentage.
+// The utilization is calculated from the CPU times between two collecting, so nothing will be
+// reported on the first collecting.
+type CPUCollector struct {
+	interval time.Duration
+	prev     *cpu.TimesStat
+
+	idle    *aura.Desc
+	busy    *aura.Desc
+	user    *aura.Desc
+	nice    *aura.Desc
+	system  *aura.Desc
+	iowait  *aura.Desc
+	irq     *aura.Desc
+	softirq *aura.Desc
+	steal   *aura.Desc
+	guest   *aura.Desc
+}
+
+// Interval implements aura.Collector.
+func (c *CPUCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Describe implements aura.Collector.
+func (c *CPUCollector) Describe(ch chan<- *aura.Desc) {
+	ch <- c.idle
+	ch <- c.busy
+	ch <- c.user
+	ch <- c.nice
+	ch <- c.system
+	ch <- c.iowait
+	ch <- c.irq
+	ch <- c.softirq
+	ch <- c.steal
+	ch <- c.guest
+}
+
+// Collect implements aura.Collector.
+func (c *CPUCollector) Collect(ch chan<- aura.Metric) {
+	times, err := cpu.Times(false)
+	if err != nil || len(times) == 0 {
+		return
+	}
+
+	cur := times[0]
+	prev := c.prev
+	c.prev = &cur
+	if prev == nil {
+		return
+	}
+
+	total := cur.Total() - prev.Total()
+	if total <= 0 {
+		return
+	}
+
+	idle := percent(cur.Idle-prev.Idle, total)
+	ch <- aura.MustNewConstMetric(c.idle, aura.GaugeValue, idle)
+	ch <- aura.MustNewConstMetric(c.busy, aura.GaugeValue, 100-idle)
+	ch <- aura.MustNewConstMetric(c.user, aura.GaugeValue, percent(cur.User-prev.User, total))
+	ch <- aura.MustNewConstMetric(c.nice, aura.GaugeValue, percent(cur.Nice-prev.Nice, total))
+	ch <- aura.MustNewConstMetric(c.system, aura.GaugeValue, percent(cur.System-prev.System, total))
+	ch <- aura.MustNewConstMetric(c.iowait, aura.GaugeValue, percent(cur.Iowait-prev.Iowait, total))
+	ch <- aura.MustNewConstMetric(c.irq, aura.GaugeValue, percent(cur.Irq-prev.Irq, total))
+	ch <- aura.MustNewConstMetric(c.softirq, aura.GaugeValue, percent(cur.Softirq-prev.Softirq, total))
+	ch <- aura.MustNewConstMetric(c.steal, aura.GaugeValue, percent(cur.Steal-prev.Steal, total))
+	ch <- aura.MustNewConstMetric(c.guest, aura.GaugeValue, percent(cur.Guest-prev.Guest, total))
+}
+
+// NewCPUCollector returns a CPUCollector.
+func NewCPUCollector(step uint32, interval time.Duration) *CPUCollector {
+	return &CPUCollector{
+		interval: interval,
+		idle:     newDesc("cpu.idle", "percentage of time the CPU is idle", step),
+		busy:     newDesc("cpu.busy", "percentage of time the CPU is busy", step),
+		user:     newDesc("cpu.user", "percentage of time spent in user mode", step),
+		nice:     newDesc("cpu.nice", "percentage of time spent in user mode with low priority", step),
+		system:   newDesc("cpu.system", "percentage of time spent in system mode", step),
+		iowait:   newDesc("cpu.iowait", "percentage of time waiting for I/O to complete", step),
+		irq:      newDesc("cpu.irq", "percentage of time servicing interrupts", step),
+		softirq:  newDesc("cpu.softirq", "percentage of time servicing softirqs", step),
+		steal:    newDesc("cpu.steal", "percentage of time stolen by other virtual machines", step),
+		guest:    newDesc("cpu.guest", "percentage of time spent running a virtual CPU for guests", step),
+	}
+}
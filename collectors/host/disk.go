@@ -0,0 +1,184 @@
+package host
+
+import (
+	"strings"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/shirou/gopsutil/disk"
+)
+
+// DiskCollector collects the usage of the mounted filesystems and the IO rates of the block devices.
+// The IO rates are calculated from the counters between two collecting, so they will not be
+// reported on the first collecting.
+type DiskCollector struct {
+	interval       time.Duration
+	devicePrefixes []string
+	prev           map[string]disk.IOCountersStat
+	prevTime       time.Time
+
+	bytesTotal        *aura.Desc
+	bytesUsed         *aura.Desc
+	bytesFree         *aura.Desc
+	bytesUsedPercent  *aura.Desc
+	bytesFreePercent  *aura.Desc
+	inodesTotal       *aura.Desc
+	inodesUsed        *aura.Desc
+	inodesFree        *aura.Desc
+	inodesUsedPercent *aura.Desc
+	inodesFreePercent *aura.Desc
+	ioReadRequests    *aura.Desc
+	ioWriteRequests   *aura.Desc
+	ioReadBytes       *aura.Desc
+	ioWriteBytes      *aura.Desc
+	ioMsecRead        *aura.Desc
+	ioMsecWrite       *aura.Desc
+	ioUtil            *aura.Desc
+}
+
+func (c *DiskCollector) match(device string) bool {
+	if len(c.devicePrefixes) == 0 {
+		return !strings.HasPrefix(device, "loop") && !strings.HasPrefix(device, "ram")
+	}
+
+	for _, prefix := range c.devicePrefixes {
+		if strings.HasPrefix(device, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Interval implements aura.Collector.
+func (c *DiskCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Describe implements aura.Collector.
+func (c *DiskCollector) Describe(ch chan<- *aura.Desc) {
+	ch <- c.bytesTotal
+	ch <- c.bytesUsed
+	ch <- c.bytesFree
+	ch <- c.bytesUsedPercent
+	ch <- c.bytesFreePercent
+	ch <- c.inodesTotal
+	ch <- c.inodesUsed
+	ch <- c.inodesFree
+	ch <- c.inodesUsedPercent
+	ch <- c.inodesFreePercent
+	ch <- c.ioReadRequests
+	ch <- c.ioWriteRequests
+	ch <- c.ioReadBytes
+	ch <- c.ioWriteBytes
+	ch <- c.ioMsecRead
+	ch <- c.ioMsecWrite
+	ch <- c.ioUtil
+}
+
+func (c *DiskCollector) collectUsage(ch chan<- aura.Metric) {
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range partitions {
+		if seen[p.Mountpoint] {
+			continue
+		}
+		seen[p.Mountpoint] = true
+
+		usage, err := disk.Usage(p.Mountpoint)
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+
+		lvs := []string{p.Mountpoint, p.Fstype}
+		ch <- aura.MustNewConstMetric(c.bytesTotal, aura.GaugeValue, usage.Total, lvs...)
+		ch <- aura.MustNewConstMetric(c.bytesUsed, aura.GaugeValue, usage.Used, lvs...)
+		ch <- aura.MustNewConstMetric(c.bytesFree, aura.GaugeValue, usage.Free, lvs...)
+		ch <- aura.MustNewConstMetric(c.bytesUsedPercent, aura.GaugeValue, usage.UsedPercent, lvs...)
+		ch <- aura.MustNewConstMetric(c.bytesFreePercent, aura.GaugeValue, 100-usage.UsedPercent, lvs...)
+
+		if usage.InodesTotal == 0 {
+			continue
+		}
+		ch <- aura.MustNewConstMetric(c.inodesTotal, aura.GaugeValue, usage.InodesTotal, lvs...)
+		ch <- aura.MustNewConstMetric(c.inodesUsed, aura.GaugeValue, usage.InodesUsed, lvs...)
+		ch <- aura.MustNewConstMetric(c.inodesFree, aura.GaugeValue, usage.InodesFree, lvs...)
+		ch <- aura.MustNewConstMetric(c.inodesUsedPercent, aura.GaugeValue, usage.InodesUsedPercent, lvs...)
+		ch <- aura.MustNewConstMetric(c.inodesFreePercent, aura.GaugeValue, 100-usage.InodesUsedPercent, lvs...)
+	}
+}
+
+func (c *DiskCollector) collectIO(ch chan<- aura.Metric) {
+	counters, err := disk.IOCounters()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	seconds := now.Sub(c.prevTime).Seconds()
+
+	cur := make(map[string]disk.IOCountersStat)
+	for device, stat := range counters {
+		if !c.match(device) {
+			continue
+		}
+		cur[device] = stat
+
+		prev, ok := c.prev[device]
+		if !ok {
+			continue
+		}
+
+		// IoTime is the milliseconds spent doing IO.
+		util := rate(stat.IoTime, prev.IoTime, seconds) / 1000 * 100
+		if util > 100 {
+			util = 100
+		}
+
+		ch <- aura.MustNewConstMetric(c.ioReadRequests, aura.GaugeValue, rate(stat.ReadCount, prev.ReadCount, seconds), device)
+		ch <- aura.MustNewConstMetric(c.ioWriteRequests, aura.GaugeValue, rate(stat.WriteCount, prev.WriteCount, seconds), device)
+		ch <- aura.MustNewConstMetric(c.ioReadBytes, aura.GaugeValue, rate(stat.ReadBytes, prev.ReadBytes, seconds), device)
+		ch <- aura.MustNewConstMetric(c.ioWriteBytes, aura.GaugeValue, rate(stat.WriteBytes, prev.WriteBytes, seconds), device)
+		ch <- aura.MustNewConstMetric(c.ioMsecRead, aura.GaugeValue, rate(stat.ReadTime, prev.ReadTime, seconds), device)
+		ch <- aura.MustNewConstMetric(c.ioMsecWrite, aura.GaugeValue, rate(stat.WriteTime, prev.WriteTime, seconds), device)
+		ch <- aura.MustNewConstMetric(c.ioUtil, aura.GaugeValue, util, device)
+	}
+
+	c.prev = cur
+	c.prevTime = now
+}
+
+// Collect implements aura.Collector.
+func (c *DiskCollector) Collect(ch chan<- aura.Metric) {
+	c.collectUsage(ch)
+	c.collectIO(ch)
+}
+
+// NewDiskCollector returns a DiskCollector, the IO rates are collected for the block devices with the
+// given prefixes, all the devices except `loop` and `ram` will be collected if devicePrefixes is empty.
+func NewDiskCollector(step uint32, interval time.Duration, devicePrefixes []string) *DiskCollector {
+	return &DiskCollector{
+		interval:          interval,
+		devicePrefixes:    devicePrefixes,
+		bytesTotal:        newDesc("df.bytes.total", "total size of the filesystem in bytes", step, "mount", "fstype"),
+		bytesUsed:         newDesc("df.bytes.used", "used size of the filesystem in bytes", step, "mount", "fstype"),
+		bytesFree:         newDesc("df.bytes.free", "free size of the filesystem in bytes", step, "mount", "fstype"),
+		bytesUsedPercent:  newDesc("df.bytes.used.percent", "percentage of the filesystem used", step, "mount", "fstype"),
+		bytesFreePercent:  newDesc("df.bytes.free.percent", "percentage of the filesystem free", step, "mount", "fstype"),
+		inodesTotal:       newDesc("df.inodes.total", "total inodes of the filesystem", step, "mount", "fstype"),
+		inodesUsed:        newDesc("df.inodes.used", "inodes used of the filesystem", step, "mount", "fstype"),
+		inodesFree:        newDesc("df.inodes.free", "inodes free of the filesystem", step, "mount", "fstype"),
+		inodesUsedPercent: newDesc("df.inodes.used.percent", "percentage of the inodes used", step, "mount", "fstype"),
+		inodesFreePercent: newDesc("df.inodes.free.percent", "percentage of the inodes free", step, "mount", "fstype"),
+		ioReadRequests:    newDesc("disk.io.read_requests", "read requests completed per second", step, "device"),
+		ioWriteRequests:   newDesc("disk.io.write_requests", "write requests completed per second", step, "device"),
+		ioReadBytes:       newDesc("disk.io.read_bytes", "bytes read per second", step, "device"),
+		ioWriteBytes:      newDesc("disk.io.write_bytes", "bytes written per second", step, "device"),
+		ioMsecRead:        newDesc("disk.io.msec_read", "milliseconds spent reading per second", step, "device"),
+		ioMsecWrite:       newDesc("disk.io.msec_write", "milliseconds spent writing per second", step, "device"),
+		ioUtil:            newDesc("disk.io.util", "percentage of time the device is busy", step, "device"),
+	}
+}
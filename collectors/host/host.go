@@ -0,0 +1,42 @@
+// Package host provides the aura collectors for the host metrics, the metrics are named after
+// the builtin metrics of falcon-agent, e.g. `cpu.idle`, `mem.memfree.percent` and `net.if.in.bytes`.
+//
+// The metrics are reported without endpoint, use the aura.RelabelHostname action to fill it.
+package host
+
+import (
+	"time"
+
+	"github.com/chenjiandongx/aura"
+)
+
+// NewCollectors returns all the host collectors.
+func NewCollectors(step uint32, interval time.Duration) []aura.Collector {
+	return []aura.Collector{
+		NewCPUCollector(step, interval),
+		NewLoadCollector(step, interval),
+		NewMemCollector(step, interval),
+		NewNetCollector(step, interval, nil),
+		NewDiskCollector(step, interval, nil),
+	}
+}
+
+func newDesc(name, help string, step uint32, labelKeys ...string) *aura.Desc {
+	return aura.NewDesc(name, help, step, labelKeys)
+}
+
+// rate returns the increment per second of a counter, a counter reset results in zero.
+func rate(cur, prev uint64, seconds float64) float64 {
+	if cur < prev || seconds <= 0 {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
+
+// percent returns the percentage of part in total.
+func percent(part, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return part / total * 100
+}
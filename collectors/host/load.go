@@ -0,0 +1,51 @@
+package host
+
+import (
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/shirou/gopsutil/load"
+)
+
+// LoadCollector collects the load average of the host.
+type LoadCollector struct {
+	interval time.Duration
+
+	load1  *aura.Desc
+	load5  *aura.Desc
+	load15 *aura.Desc
+}
+
+// Interval implements aura.Collector.
+func (c *LoadCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Describe implements aura.Collector.
+func (c *LoadCollector) Describe(ch chan<- *aura.Desc) {
+	ch <- c.load1
+	ch <- c.load5
+	ch <- c.load15
+}
+
+// Collect implements aura.Collector.
+func (c *LoadCollector) Collect(ch chan<- aura.Metric) {
+	avg, err := load.Avg()
+	if err != nil {
+		return
+	}
+
+	ch <- aura.MustNewConstMetric(c.load1, aura.GaugeValue, avg.Load1)
+	ch <- aura.MustNewConstMetric(c.load5, aura.GaugeValue, avg.Load5)
+	ch <- aura.MustNewConstMetric(c.load15, aura.GaugeValue, avg.Load15)
+}
+
+// NewLoadCollector returns a LoadCollector.
+func NewLoadCollector(step uint32, interval time.Duration) *LoadCollector {
+	return &LoadCollector{
+		interval: interval,
+		load1:    newDesc("load.1min", "load average over the last 1 minute", step),
+		load5:    newDesc("load.5min", "load average over the last 5 minutes", step),
+		load15:   newDesc("load.15min", "load average over the last 15 minutes", step),
+	}
+}
@@ -0,0 +1,81 @@
+package host
+
+import (
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/shirou/gopsutil/mem"
+)
+
+// MemCollector collects the memory and swap usage in bytes and percentage.
+type MemCollector struct {
+	interval time.Duration
+
+	memTotal        *aura.Desc
+	memUsed         *aura.Desc
+	memFree         *aura.Desc
+	memUsedPercent  *aura.Desc
+	memFreePercent  *aura.Desc
+	swapTotal       *aura.Desc
+	swapUsed        *aura.Desc
+	swapFree        *aura.Desc
+	swapUsedPercent *aura.Desc
+	swapFreePercent *aura.Desc
+}
+
+// Interval implements aura.Collector.
+func (c *MemCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Describe implements aura.Collector.
+func (c *MemCollector) Describe(ch chan<- *aura.Desc) {
+	ch <- c.memTotal
+	ch <- c.memUsed
+	ch <- c.memFree
+	ch <- c.memUsedPercent
+	ch <- c.memFreePercent
+	ch <- c.swapTotal
+	ch <- c.swapUsed
+	ch <- c.swapFree
+	ch <- c.swapUsedPercent
+	ch <- c.swapFreePercent
+}
+
+// Collect implements aura.Collector.
+func (c *MemCollector) Collect(ch chan<- aura.Metric) {
+	if vm, err := mem.VirtualMemory(); err == nil {
+		// the memory available for programs is regarded as free as falcon-agent does.
+		used := vm.Total - vm.Available
+		ch <- aura.MustNewConstMetric(c.memTotal, aura.GaugeValue, vm.Total)
+		ch <- aura.MustNewConstMetric(c.memUsed, aura.GaugeValue, used)
+		ch <- aura.MustNewConstMetric(c.memFree, aura.GaugeValue, vm.Available)
+		ch <- aura.MustNewConstMetric(c.memUsedPercent, aura.GaugeValue, percent(float64(used), float64(vm.Total)))
+		ch <- aura.MustNewConstMetric(c.memFreePercent, aura.GaugeValue, percent(float64(vm.Available), float64(vm.Total)))
+	}
+
+	if swap, err := mem.SwapMemory(); err == nil {
+		ch <- aura.MustNewConstMetric(c.swapTotal, aura.GaugeValue, swap.Total)
+		ch <- aura.MustNewConstMetric(c.swapUsed, aura.GaugeValue, swap.Used)
+		ch <- aura.MustNewConstMetric(c.swapFree, aura.GaugeValue, swap.Free)
+		ch <- aura.MustNewConstMetric(c.swapUsedPercent, aura.GaugeValue, percent(float64(swap.Used), float64(swap.Total)))
+		ch <- aura.MustNewConstMetric(c.swapFreePercent, aura.GaugeValue, percent(float64(swap.Free), float64(swap.Total)))
+	}
+}
+
+// NewMemCollector returns a MemCollector.
+func NewMemCollector(step uint32, interval time.Duration) *MemCollector {
+	return &MemCollector{
+		interval:        interval,
+		memTotal:        newDesc("mem.memtotal", "total amount of memory in bytes", step),
+		memUsed:         newDesc("mem.memused", "memory used in bytes", step),
+		memFree:         newDesc("mem.memfree", "memory available in bytes", step),
+		memUsedPercent:  newDesc("mem.memused.percent", "percentage of memory used", step),
+		memFreePercent:  newDesc("mem.memfree.percent", "percentage of memory available", step),
+		swapTotal:       newDesc("mem.swaptotal", "total amount of swap in bytes", step),
+		swapUsed:        newDesc("mem.swapused", "swap used in bytes", step),
+		swapFree:        newDesc("mem.swapfree", "swap free in bytes", step),
+		swapUsedPercent: newDesc("mem.swapused.percent", "percentage of swap used", step),
+		swapFreePercent: newDesc("mem.swapfree.percent", "percentage of swap free", step),
+	}
+}
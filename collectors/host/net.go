@@ -0,0 +1,112 @@
+package host
+
+import (
+	"strings"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/shirou/gopsutil/net"
+)
+
+// NetCollector collects the traffic rates per second of the network interfaces.
+// The rates are calculated from the counters between two collecting, so nothing will be
+// reported on the first collecting.
+type NetCollector struct {
+	interval      time.Duration
+	ifacePrefixes []string
+	prev          map[string]net.IOCountersStat
+	prevTime      time.Time
+
+	inBytes    *aura.Desc
+	inPackets  *aura.Desc
+	inErrors   *aura.Desc
+	inDropped  *aura.Desc
+	outBytes   *aura.Desc
+	outPackets *aura.Desc
+	outErrors  *aura.Desc
+	outDropped *aura.Desc
+}
+
+func (c *NetCollector) match(iface string) bool {
+	if len(c.ifacePrefixes) == 0 {
+		return iface != "lo"
+	}
+
+	for _, prefix := range c.ifacePrefixes {
+		if strings.HasPrefix(iface, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Interval implements aura.Collector.
+func (c *NetCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Describe implements aura.Collector.
+func (c *NetCollector) Describe(ch chan<- *aura.Desc) {
+	ch <- c.inBytes
+	ch <- c.inPackets
+	ch <- c.inErrors
+	ch <- c.inDropped
+	ch <- c.outBytes
+	ch <- c.outPackets
+	ch <- c.outErrors
+	ch <- c.outDropped
+}
+
+// Collect implements aura.Collector.
+func (c *NetCollector) Collect(ch chan<- aura.Metric) {
+	counters, err := net.IOCounters(true)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	seconds := now.Sub(c.prevTime).Seconds()
+
+	cur := make(map[string]net.IOCountersStat)
+	for _, stat := range counters {
+		if !c.match(stat.Name) {
+			continue
+		}
+		cur[stat.Name] = stat
+
+		// the interface may be new since the last collecting.
+		prev, ok := c.prev[stat.Name]
+		if !ok {
+			continue
+		}
+
+		ch <- aura.MustNewConstMetric(c.inBytes, aura.GaugeValue, rate(stat.BytesRecv, prev.BytesRecv, seconds), stat.Name)
+		ch <- aura.MustNewConstMetric(c.inPackets, aura.GaugeValue, rate(stat.PacketsRecv, prev.PacketsRecv, seconds), stat.Name)
+		ch <- aura.MustNewConstMetric(c.inErrors, aura.GaugeValue, rate(stat.Errin, prev.Errin, seconds), stat.Name)
+		ch <- aura.MustNewConstMetric(c.inDropped, aura.GaugeValue, rate(stat.Dropin, prev.Dropin, seconds), stat.Name)
+		ch <- aura.MustNewConstMetric(c.outBytes, aura.GaugeValue, rate(stat.BytesSent, prev.BytesSent, seconds), stat.Name)
+		ch <- aura.MustNewConstMetric(c.outPackets, aura.GaugeValue, rate(stat.PacketsSent, prev.PacketsSent, seconds), stat.Name)
+		ch <- aura.MustNewConstMetric(c.outErrors, aura.GaugeValue, rate(stat.Errout, prev.Errout, seconds), stat.Name)
+		ch <- aura.MustNewConstMetric(c.outDropped, aura.GaugeValue, rate(stat.Dropout, prev.Dropout, seconds), stat.Name)
+	}
+
+	c.prev = cur
+	c.prevTime = now
+}
+
+// NewNetCollector returns a NetCollector for the interfaces with the given prefixes,
+// all the interfaces except `lo` will be collected if ifacePrefixes is empty.
+func NewNetCollector(step uint32, interval time.Duration, ifacePrefixes []string) *NetCollector {
+	return &NetCollector{
+		interval:      interval,
+		ifacePrefixes: ifacePrefixes,
+		inBytes:       newDesc("net.if.in.bytes", "bytes received per second", step, "iface"),
+		inPackets:     newDesc("net.if.in.packets", "packets received per second", step, "iface"),
+		inErrors:      newDesc("net.if.in.errors", "errors while receiving per second", step, "iface"),
+		inDropped:     newDesc("net.if.in.dropped", "incoming packets dropped per second", step, "iface"),
+		outBytes:      newDesc("net.if.out.bytes", "bytes sent per second", step, "iface"),
+		outPackets:    newDesc("net.if.out.packets", "packets sent per second", step, "iface"),
+		outErrors:     newDesc("net.if.out.errors", "errors while sending per second", step, "iface"),
+		outDropped:    newDesc("net.if.out.dropped", "outgoing packets dropped per second", step, "iface"),
+	}
+}
@@ -0,0 +1,347 @@
+// Package proc provides an aura collector for the processes matched by the rules, it works like
+// the `proc.num` checks of falcon, e.g. `proc.num`, `proc.cpu.percent` and `proc.mem.rss`.
+//
+// All the metrics are labeled by `name` which is the name of the rule.
+package proc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/shirou/gopsutil/process"
+)
+
+// Rule matches the processes, a process is matched if all the non-empty conditions are satisfied.
+type Rule struct {
+	// Name is the value of the `name` label.
+	Name string
+	// Exe matches the process name or the base name of the executable.
+	Exe string
+	// Cmdline is a regex which matches the command line joined by spaces.
+	Cmdline string
+	// Pidfile matches the process whose pid is written in the file.
+	Pidfile string
+	// Cgroup matches the processes whose cgroup path contains it, only works on linux.
+	Cgroup string
+
+	cmdline *regexp.Regexp
+}
+
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("proc rule name should not be empty")
+	}
+	if r.Exe == "" && r.Cmdline == "" && r.Pidfile == "" && r.Cgroup == "" {
+		return fmt.Errorf("proc rule %q has no condition", r.Name)
+	}
+
+	if r.Cmdline != "" {
+		regex, err := regexp.Compile(r.Cmdline)
+		if err != nil {
+			return fmt.Errorf("invalid cmdline regex of proc rule %q: %v", r.Name, err)
+		}
+		r.cmdline = regex
+	}
+	return nil
+}
+
+// readPidfile returns the pid in the pidfile, zero means the pidfile is unavailable.
+func readPidfile(path string) int32 {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(bs)), 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int32(pid)
+}
+
+func (r *Rule) match(p *proc, pidfilePid int32) bool {
+	if r.Pidfile != "" && p.pid != pidfilePid {
+		return false
+	}
+	if r.Exe != "" && p.name != r.Exe && filepath.Base(p.exe()) != r.Exe {
+		return false
+	}
+	if r.cmdline != nil && !r.cmdline.MatchString(p.cmdline) {
+		return false
+	}
+	if r.Cgroup != "" && !strings.Contains(p.cgroup, r.Cgroup) {
+		return false
+	}
+	return true
+}
+
+// proc is a process seen by the collector, it is identified by the pid and the create time
+// since the pids may be reused.
+type proc struct {
+	self       *process.Process
+	pid        int32
+	createTime int64
+	name       string
+	cmdline    string
+	cgroup     string
+
+	exePath   *string
+	prevCPU   float64
+	prevRead  uint64
+	prevWrite uint64
+	prevTime  time.Time
+}
+
+func (p *proc) exe() string {
+	if p.exePath == nil {
+		exe, _ := p.self.Exe()
+		p.exePath = &exe
+	}
+	return *p.exePath
+}
+
+func hostProc(elem ...string) string {
+	root := os.Getenv("HOST_PROC")
+	if root == "" {
+		root = "/proc"
+	}
+	return filepath.Join(append([]string{root}, elem...)...)
+}
+
+func newProc(pid int32, createTime int64) *proc {
+	self, _ := process.NewProcess(pid)
+	if self == nil {
+		self = &process.Process{Pid: pid}
+	}
+
+	p := &proc{self: self, pid: pid, createTime: createTime}
+	p.name, _ = self.Name()
+	p.cmdline, _ = self.Cmdline()
+	if bs, err := ioutil.ReadFile(hostProc(strconv.Itoa(int(pid)), "cgroup")); err == nil {
+		p.cgroup = string(bs)
+	}
+	return p
+}
+
+// usage is the resource usage of a process.
+type usage struct {
+	cpuPercent float64
+	rss        uint64
+	threads    int32
+	fds        int32
+	readBytes  float64
+	writeBytes float64
+}
+
+// rate returns the increment per second of a counter, a counter reset results in zero.
+func rate(cur, prev uint64, seconds float64) float64 {
+	if cur < prev || seconds <= 0 {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
+
+// usage returns the resource usage of the process. The CPU percent and IO bytes are calculated
+// from the values between two collecting, so a new process contributes nothing to them at the first time.
+func (p *proc) usage(now time.Time) usage {
+	u := usage{}
+	if mem, err := p.self.MemoryInfo(); err == nil {
+		u.rss = mem.RSS
+	}
+	if threads, err := p.self.NumThreads(); err == nil {
+		u.threads = threads
+	}
+	if fds, err := p.self.NumFDs(); err == nil {
+		u.fds = fds
+	}
+
+	seconds := now.Sub(p.prevTime).Seconds()
+	first := p.prevTime.IsZero()
+	p.prevTime = now
+
+	if times, err := p.self.Times(); err == nil {
+		cpu := times.User + times.System
+		if !first && seconds > 0 && cpu >= p.prevCPU {
+			u.cpuPercent = (cpu - p.prevCPU) / seconds * 100
+		}
+		p.prevCPU = cpu
+	}
+	if io, err := p.self.IOCounters(); err == nil {
+		if !first {
+			u.readBytes = rate(io.ReadBytes, p.prevRead, seconds)
+			u.writeBytes = rate(io.WriteBytes, p.prevWrite, seconds)
+		}
+		p.prevRead, p.prevWrite = io.ReadBytes, io.WriteBytes
+	}
+	return u
+}
+
+// group is the sum of the processes matched by a rule.
+type group struct {
+	num int
+	usage
+}
+
+func (g *group) add(u usage) {
+	g.num++
+	g.cpuPercent += u.cpuPercent
+	g.rss += u.rss
+	g.threads += u.threads
+	g.fds += u.fds
+	g.readBytes += u.readBytes
+	g.writeBytes += u.writeBytes
+}
+
+// Collector collects the number and the resource usage of the processes matched by the rules.
+// The processes are scanned on every collecting, the exited ones are forgotten and the new ones
+// are picked up.
+type Collector struct {
+	interval time.Duration
+	rules    []*Rule
+
+	mtx   sync.Mutex
+	procs map[int32]*proc
+
+	num        *aura.Desc
+	cpuPercent *aura.Desc
+	rss        *aura.Desc
+	threads    *aura.Desc
+	fds        *aura.Desc
+	readBytes  *aura.Desc
+	writeBytes *aura.Desc
+}
+
+// Interval implements aura.Collector.
+func (c *Collector) Interval() time.Duration {
+	return c.interval
+}
+
+// Describe implements aura.Collector.
+func (c *Collector) Describe(ch chan<- *aura.Desc) {
+	ch <- c.num
+	ch <- c.cpuPercent
+	ch <- c.rss
+	ch <- c.threads
+	ch <- c.fds
+	ch <- c.readBytes
+	ch <- c.writeBytes
+}
+
+// scan refreshes the processes cache, returns the processes alive.
+func (c *Collector) scan() []*proc {
+	pids, err := process.Pids()
+	if err != nil {
+		return nil
+	}
+
+	alive := make(map[int32]*proc, len(pids))
+	procs := make([]*proc, 0, len(pids))
+	for _, pid := range pids {
+		self := &process.Process{Pid: pid}
+		createTime, err := self.CreateTime()
+		if err != nil {
+			continue
+		}
+
+		p, ok := c.procs[pid]
+		if !ok || p.createTime != createTime {
+			p = newProc(pid, createTime)
+		}
+		alive[pid] = p
+		procs = append(procs, p)
+	}
+
+	c.procs = alive
+	return procs
+}
+
+// Collect implements aura.Collector.
+func (c *Collector) Collect(ch chan<- aura.Metric) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	procs := c.scan()
+	now := time.Now()
+
+	groups := make([]*group, len(c.rules))
+	pidfilePids := make([]int32, len(c.rules))
+	for i, rule := range c.rules {
+		groups[i] = &group{}
+		if rule.Pidfile != "" {
+			pidfilePids[i] = readPidfile(rule.Pidfile)
+		}
+	}
+
+	for _, p := range procs {
+		var u *usage
+		for i, rule := range c.rules {
+			if !rule.match(p, pidfilePids[i]) {
+				continue
+			}
+			// the usage is sampled once even if the process is matched by several rules.
+			if u == nil {
+				sampled := p.usage(now)
+				u = &sampled
+			}
+			groups[i].add(*u)
+		}
+	}
+
+	for i, rule := range c.rules {
+		g := groups[i]
+		ch <- aura.MustNewConstMetric(c.num, aura.GaugeValue, g.num, rule.Name)
+		ch <- aura.MustNewConstMetric(c.cpuPercent, aura.GaugeValue, g.cpuPercent, rule.Name)
+		ch <- aura.MustNewConstMetric(c.rss, aura.GaugeValue, g.rss, rule.Name)
+		ch <- aura.MustNewConstMetric(c.threads, aura.GaugeValue, g.threads, rule.Name)
+		ch <- aura.MustNewConstMetric(c.fds, aura.GaugeValue, g.fds, rule.Name)
+		ch <- aura.MustNewConstMetric(c.readBytes, aura.GaugeValue, g.readBytes, rule.Name)
+		ch <- aura.MustNewConstMetric(c.writeBytes, aura.GaugeValue, g.writeBytes, rule.Name)
+	}
+}
+
+func newDesc(name, help string, step uint32) *aura.Desc {
+	return aura.NewDesc(name, help, step, []string{"name"})
+}
+
+// NewCollector returns a Collector for the processes matched by the rules.
+func NewCollector(step uint32, interval time.Duration, rules ...*Rule) (*Collector, error) {
+	names := make(map[string]bool)
+	for _, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicated proc rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+
+	return &Collector{
+		interval:   interval,
+		rules:      rules,
+		procs:      make(map[int32]*proc),
+		num:        newDesc("proc.num", "number of the processes matched", step),
+		cpuPercent: newDesc("proc.cpu.percent", "CPU utilization of the processes matched in percentage", step),
+		rss:        newDesc("proc.mem.rss", "resident set size of the processes matched in bytes", step),
+		threads:    newDesc("proc.threads", "number of threads of the processes matched", step),
+		fds:        newDesc("proc.fds", "number of open file descriptors of the processes matched", step),
+		readBytes:  newDesc("proc.io.read.bytes", "bytes read from storage per second", step),
+		writeBytes: newDesc("proc.io.write.bytes", "bytes written to storage per second", step),
+	}, nil
+}
+
+// MustNewCollector is like NewCollector but panics if the rules are invalid.
+func MustNewCollector(step uint32, interval time.Duration, rules ...*Rule) *Collector {
+	c, err := NewCollector(step, interval, rules...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,215 @@
+// Package runtime provides an aura collector for the health of the instrumented service itself,
+// including the Go runtime statistics and the resource usage of the current process.
+package runtime
+
+import (
+	"fmt"
+	"os"
+	goruntime "runtime"
+	"sync"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/rcrowley/go-metrics"
+	"github.com/shirou/gopsutil/process"
+)
+
+// Opts specifies the namespace of the metrics and the percentiles of the GC pauses.
+type Opts struct {
+	Namespace   string
+	Percentiles []float64
+}
+
+// DefaultOpts holds the Opts by default case.
+var DefaultOpts = &Opts{
+	Namespace:   "go",
+	Percentiles: []float64{0.5, 0.9, 0.99},
+}
+
+// Collector collects the goroutines, GC, heap and cgo statistics of the Go runtime,
+// and the open fds, threads, RSS and CPU seconds of the current process.
+//
+// The GC pause distribution is calculated from the pauses since the last collecting.
+type Collector struct {
+	interval time.Duration
+	opts     *Opts
+	step     uint32
+
+	mtx       sync.Mutex
+	lastNumGC uint32
+	proc      *process.Process
+
+	goroutines   *aura.Desc
+	gcCount      *aura.Desc
+	gcPause      *aura.Desc
+	gcPauseTotal *aura.Desc
+	heapAlloc    *aura.Desc
+	heapInuse    *aura.Desc
+	heapIdle     *aura.Desc
+	heapSys      *aura.Desc
+	heapReleased *aura.Desc
+	heapObjects  *aura.Desc
+	stackInuse   *aura.Desc
+	sys          *aura.Desc
+	cgoCalls     *aura.Desc
+	openFds      *aura.Desc
+	threads      *aura.Desc
+	rss          *aura.Desc
+	cpuSeconds   *aura.Desc
+}
+
+func (c *Collector) fqName(name string) string {
+	return aura.BuildFQName(c.opts.Namespace, "", name)
+}
+
+func (c *Collector) desc(name, help string) *aura.Desc {
+	return aura.NewDesc(c.fqName(name), help, c.step, nil)
+}
+
+// Interval implements aura.Collector.
+func (c *Collector) Interval() time.Duration {
+	return c.interval
+}
+
+// Describe implements aura.Collector.
+func (c *Collector) Describe(ch chan<- *aura.Desc) {
+	ch <- c.goroutines
+	ch <- c.gcCount
+	ch <- c.gcPause
+	ch <- c.gcPauseTotal
+	ch <- c.heapAlloc
+	ch <- c.heapInuse
+	ch <- c.heapIdle
+	ch <- c.heapSys
+	ch <- c.heapReleased
+	ch <- c.heapObjects
+	ch <- c.stackInuse
+	ch <- c.sys
+	ch <- c.cgoCalls
+	ch <- c.openFds
+	ch <- c.threads
+	ch <- c.rss
+	ch <- c.cpuSeconds
+}
+
+// popGCPauses returns the GC pauses in nanoseconds since the last collecting, at most the
+// latest 256 pauses are kept by the runtime.
+func (c *Collector) popGCPauses(ms *goruntime.MemStats) []int64 {
+	size := uint32(len(ms.PauseNs))
+	n := ms.NumGC - c.lastNumGC
+	if n > size {
+		n = size
+	}
+	c.lastNumGC = ms.NumGC
+
+	pauses := make([]int64, 0, n)
+	for i := ms.NumGC - n + 1; n > 0 && i <= ms.NumGC; i++ {
+		pauses = append(pauses, int64(ms.PauseNs[(i+size-1)%size]))
+	}
+	return pauses
+}
+
+func (c *Collector) popGCPause(suffix string, ns float64) aura.Metric {
+	return aura.Metric{
+		Metric:    fmt.Sprintf("%s.%s", c.fqName("gc.pause"), suffix),
+		Step:      c.step,
+		Value:     ns / float64(time.Second),
+		Type:      aura.GaugeValue,
+		Labels:    map[string]string{},
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+func (c *Collector) collectGCPauses(ch chan<- aura.Metric, pauses []int64) {
+	if len(pauses) == 0 {
+		return
+	}
+
+	ch <- c.popGCPause("min", float64(metrics.SampleMin(pauses)))
+	ch <- c.popGCPause("max", float64(metrics.SampleMax(pauses)))
+	ch <- c.popGCPause("mean", metrics.SampleMean(pauses))
+	for idx, v := range metrics.SamplePercentiles(pauses, c.opts.Percentiles) {
+		ch <- c.popGCPause(fmt.Sprintf("%.2f", c.opts.Percentiles[idx]), v)
+	}
+}
+
+func (c *Collector) collectProcess(ch chan<- aura.Metric) {
+	if c.proc == nil {
+		proc, err := process.NewProcess(int32(os.Getpid()))
+		if err != nil {
+			return
+		}
+		c.proc = proc
+	}
+
+	if fds, err := c.proc.NumFDs(); err == nil {
+		ch <- aura.MustNewConstMetric(c.openFds, aura.GaugeValue, fds)
+	}
+	if threads, err := c.proc.NumThreads(); err == nil {
+		ch <- aura.MustNewConstMetric(c.threads, aura.GaugeValue, threads)
+	}
+	if mem, err := c.proc.MemoryInfo(); err == nil {
+		ch <- aura.MustNewConstMetric(c.rss, aura.GaugeValue, mem.RSS)
+	}
+	if times, err := c.proc.Times(); err == nil {
+		ch <- aura.MustNewConstMetric(c.cpuSeconds, aura.CounterValue, times.User+times.System)
+	}
+}
+
+// Collect implements aura.Collector.
+func (c *Collector) Collect(ch chan<- aura.Metric) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	ms := &goruntime.MemStats{}
+	goruntime.ReadMemStats(ms)
+
+	ch <- aura.MustNewConstMetric(c.goroutines, aura.GaugeValue, goruntime.NumGoroutine())
+	ch <- aura.MustNewConstMetric(c.gcCount, aura.CounterValue, ms.NumGC)
+	ch <- aura.MustNewConstMetric(c.gcPauseTotal, aura.CounterValue, float64(ms.PauseTotalNs)/float64(time.Second))
+	ch <- aura.MustNewConstMetric(c.heapAlloc, aura.GaugeValue, ms.HeapAlloc)
+	ch <- aura.MustNewConstMetric(c.heapInuse, aura.GaugeValue, ms.HeapInuse)
+	ch <- aura.MustNewConstMetric(c.heapIdle, aura.GaugeValue, ms.HeapIdle)
+	ch <- aura.MustNewConstMetric(c.heapSys, aura.GaugeValue, ms.HeapSys)
+	ch <- aura.MustNewConstMetric(c.heapReleased, aura.GaugeValue, ms.HeapReleased)
+	ch <- aura.MustNewConstMetric(c.heapObjects, aura.GaugeValue, ms.HeapObjects)
+	ch <- aura.MustNewConstMetric(c.stackInuse, aura.GaugeValue, ms.StackInuse)
+	ch <- aura.MustNewConstMetric(c.sys, aura.GaugeValue, ms.Sys)
+	ch <- aura.MustNewConstMetric(c.cgoCalls, aura.CounterValue, goruntime.NumCgoCall())
+
+	c.collectGCPauses(ch, c.popGCPauses(ms))
+	c.collectProcess(ch)
+}
+
+// NewCollector returns a Collector for the Go runtime and the current process.
+func NewCollector(step uint32, interval time.Duration, opts *Opts) *Collector {
+	if opts == nil {
+		opts = DefaultOpts
+	}
+
+	c := &Collector{interval: interval, opts: opts, step: step}
+
+	// pauses before the collector is created are not counted in the distribution.
+	ms := &goruntime.MemStats{}
+	goruntime.ReadMemStats(ms)
+	c.lastNumGC = ms.NumGC
+
+	c.goroutines = c.desc("goroutines", "number of goroutines that currently exist")
+	c.gcCount = c.desc("gc.count", "number of completed GC cycles")
+	c.gcPause = c.desc("gc.pause", "distribution of the GC pauses in seconds since the last collecting")
+	c.gcPauseTotal = c.desc("gc.pause.total", "cumulative GC pauses in seconds")
+	c.heapAlloc = c.desc("heap.alloc", "bytes of allocated heap objects")
+	c.heapInuse = c.desc("heap.inuse", "bytes in in-use spans")
+	c.heapIdle = c.desc("heap.idle", "bytes in idle spans")
+	c.heapSys = c.desc("heap.sys", "bytes of heap memory obtained from the OS")
+	c.heapReleased = c.desc("heap.released", "bytes of physical memory returned to the OS")
+	c.heapObjects = c.desc("heap.objects", "number of allocated heap objects")
+	c.stackInuse = c.desc("stack.inuse", "bytes in stack spans")
+	c.sys = c.desc("sys", "total bytes of memory obtained from the OS")
+	c.cgoCalls = c.desc("cgo.calls", "number of cgo calls made by the current process")
+	c.openFds = c.desc("process.open_fds", "number of open file descriptors")
+	c.threads = c.desc("process.threads", "number of OS threads")
+	c.rss = c.desc("process.rss", "resident set size in bytes")
+	c.cpuSeconds = c.desc("process.cpu.seconds", "total user and system CPU time spent in seconds")
+	return c
+}
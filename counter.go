@@ -1,69 +1,117 @@
 package aura
 
 import (
-	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/rcrowley/go-metrics"
 )
 
-// Counter is just a gauge for an AtomicLong instance. You can increment or decrement its value.
+// Counter counts the events monotonically, it is reported as specified by the CounterMode.
 type Counter interface {
 	Collector
 
 	Clear()
 	Count() int64
 	Rate() float64
+
+	// Deprecated: a decreased counter breaks the falcon COUNTER type, use UpDownCounter instead.
 	Dec(int64)
 	Inc(int64)
 }
 
+// CounterMode specifies how a Counter is reported.
+type CounterMode string
+
+const (
+	// CounterModeRate reports the increase per second since the last collecting as a gauge, it is the default mode.
+	CounterModeRate CounterMode = "rate"
+
+	// CounterModeDelta reports the increase since the last collecting as a gauge.
+	CounterModeDelta CounterMode = "delta"
+
+	// CounterModeCumulative reports the raw cumulative count with the CounterValue type,
+	// so the rate is derived on the server side, e.g. by the falcon COUNTER type.
+	CounterModeCumulative CounterMode = "cumulative"
+)
+
+// CounterOpts specifies the options of a Counter or a CounterVec.
+type CounterOpts struct {
+	Mode CounterMode
+
+	// VecOpts only works for the CounterVec.
+	VecOpts
+}
+
+// DefaultCounterOpts holds the CounterOpts by default case.
+var DefaultCounterOpts = &CounterOpts{}
+
 type counter struct {
 	*Desc
+	touched
 
 	prev     int64
+	mode     CounterMode
 	self     metrics.Counter
 	labels   map[string]string
 	interval time.Duration
 }
 
+// CounterVec is a Collector that bundles a set of Counters which have the same Desc but different label values.
+// It is safe for concurrent use.
 type CounterVec struct {
-	*Desc
-
-	counters map[string]*counter
-	interval time.Duration
+	*metricVec
 }
 
 func (c *counter) popMetric(desc *Desc) Metric {
 	cnt := c.self.Count()
+	prev := atomic.SwapInt64(&c.prev, cnt)
+
+	var value interface{}
+	vt := GaugeValue
+	switch c.mode {
+	case CounterModeDelta:
+		value = cnt - prev
+	case CounterModeCumulative:
+		value = cnt
+		vt = CounterValue
+	default:
+		value = float64(cnt-prev) / float64(desc.step)
+	}
 
-	m := Metric{
+	return Metric{
 		Endpoint:  c.labels["endpoint"],
 		Metric:    desc.fqName,
 		Step:      desc.step,
-		Value:     c.Rate(),
-		Type:      GaugeValue,
+		Value:     value,
+		Type:      vt,
 		Labels:    c.labels,
 		Timestamp: time.Now().Unix(),
 	}
+}
 
-	c.prev = cnt
-	return m
+func (c *counter) collect(desc *Desc, ch chan<- Metric) {
+	ch <- c.popMetric(desc)
 }
 
 // Inc increases the counter.
 func (c *counter) Inc(i int64) {
 	c.self.Inc(i)
+	c.touch()
 }
 
 // Dec decreases the counter.
+//
+// Deprecated: use UpDownCounter instead.
 func (c *counter) Dec(i int64) {
 	c.self.Dec(i)
+	c.touch()
 }
 
 // Clear resets the counter to zero.
 func (c *counter) Clear() {
 	c.self.Clear()
+	c.touch()
 }
 
 // Count returns the number of the counter.
@@ -73,7 +121,7 @@ func (c *counter) Count() int64 {
 
 // Rate returns the increasing rate of the counter
 func (c *counter) Rate() float64 {
-	return float64(c.self.Count()-c.prev) / float64(c.Desc.step)
+	return float64(c.self.Count()-atomic.LoadInt64(&c.prev)) / float64(c.Desc.step)
 }
 
 // Interval implements aura.Collector.
@@ -88,75 +136,105 @@ func (c *counter) Describe(ch chan<- *Desc) {
 
 // Collect implements aura.Collector.
 func (c *counter) Collect(ch chan<- Metric) {
-	ch <- c.popMetric(c.Desc)
+	c.collect(c.Desc, ch)
 }
 
-func (cv *CounterVec) WithLabelValues(lvs ...string) Counter {
-	if len(cv.Desc.labelKeys) != len(lvs) {
-		panic(fmt.Sprintf("counter(%s): expected %d label values but go %d",
-			cv.Desc.fqName, len(cv.Desc.labelKeys), len(lvs)),
-		)
+// GetMetricWithLabelValues returns the counter with the label values, the curried ones should not be given.
+// An error is returned if the number of label values is wrong or any value is invalid.
+func (cv *CounterVec) GetMetricWithLabelValues(lvs ...string) (Counter, error) {
+	lvs, err := cv.labelValues(lvs)
+	if err != nil {
+		return nil, err
 	}
 
-	return cv.searchCounter(lvs...)
+	return cv.searchCounter(lvs...), nil
 }
 
-func (cv *CounterVec) With(labels map[string]string) Counter {
-	for k := range labels {
-		if !cv.Desc.IsKeyIn(k) {
-			panic(fmt.Sprintf("counter(%s): expected label key: %s, but it dosen't exists", cv.Desc.fqName, k))
-		}
+// GetMetricWith returns the counter with the labels, the missing label keys are regarded as empty values.
+// An error is returned if any label key is unknown or curried, or any value is invalid.
+func (cv *CounterVec) GetMetricWith(labels map[string]string) (Counter, error) {
+	lvs, err := cv.labelValuesFromMap(labels)
+	if err != nil {
+		return nil, err
 	}
 
-	lvs := make([]string, 0)
-	for _, key := range cv.Desc.labelKeys {
-		lvs = append(lvs, labels[key])
-	}
-
-	return cv.searchCounter(lvs...)
+	return cv.searchCounter(lvs...), nil
 }
 
-func (cv *CounterVec) searchCounter(lvs ...string) Counter {
-	lbp := makeLabelPairs(cv.Desc.fqName, cv.Desc.labelKeys, lvs)
-	lbm := makeLabelMap(cv.Desc.labelKeys, lvs)
-	_, ok := cv.counters[lbp]
-	if !ok {
-		cv.counters[lbp] = &counter{self: metrics.NewCounter(), labels: lbm, Desc: &Desc{step: cv.step}}
+// WithLabelValues works as GetMetricWithLabelValues but panics if an error occurs.
+func (cv *CounterVec) WithLabelValues(lvs ...string) Counter {
+	m, err := cv.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		panic(err)
 	}
+	return m
+}
 
-	return cv.counters[lbp]
+// With works as GetMetricWith but panics if an error occurs.
+func (cv *CounterVec) With(labels map[string]string) Counter {
+	m, err := cv.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return m
 }
 
-// Interval implements aura.Collector.
-func (cv *CounterVec) Interval() time.Duration {
-	return cv.interval
+func (cv *CounterVec) searchCounter(lvs ...string) Counter {
+	return cv.child(lvs).(*counter)
 }
 
-// Describe implements aura.Collector.
-func (cv *CounterVec) Describe(ch chan<- *Desc) {
-	ch <- cv.Desc
+// CurryWith returns a CounterVec with the labels bound, the returned one shares the counters with cv.
+// It returns an error if any label key is unknown or has been curried.
+func (cv *CounterVec) CurryWith(labels map[string]string) (*CounterVec, error) {
+	mv, err := cv.curryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &CounterVec{metricVec: mv}, nil
 }
 
-// Collect implements aura.Collector.
-func (cv *CounterVec) Collect(ch chan<- Metric) {
-	for _, c := range cv.counters {
-		ch <- c.popMetric(cv.Desc)
+// MustCurryWith is like CurryWith but panics if an error occurs.
+func (cv *CounterVec) MustCurryWith(labels map[string]string) *CounterVec {
+	curried, err := cv.CurryWith(labels)
+	if err != nil {
+		panic(err)
 	}
+	return curried
 }
 
-func NewCounter(fqName, help string, step uint32, interval time.Duration) Counter {
+// NewCounter returns a Counter, the DefaultCounterOpts will be used if opts is not given.
+func NewCounter(fqName, help string, step uint32, interval time.Duration, opts ...*CounterOpts) Counter {
+	opt := DefaultCounterOpts
+	if len(opts) > 0 && opts[0] != nil {
+		opt = opts[0]
+	}
+
 	return &counter{
 		Desc:     NewDesc(fqName, help, step, nil),
+		mode:     opt.Mode,
 		self:     metrics.NewCounter(),
 		labels:   map[string]string{},
 		interval: interval,
 	}
 }
 
-func NewCounterVec(fqName, help string, step uint32, interval time.Duration, labelKeys []string) *CounterVec {
+// NewCounterVec returns a CounterVec, the DefaultCounterOpts will be used if opts is not given.
+func NewCounterVec(fqName, help string, step uint32, interval time.Duration, labelKeys []string, opts ...*CounterOpts) *CounterVec {
+	opt := DefaultCounterOpts
+	if len(opts) > 0 && opts[0] != nil {
+		opt = opts[0]
+	}
+
+	desc := NewDesc(fqName, help, step, labelKeys)
 	return &CounterVec{
-		Desc:     NewDesc(fqName, help, step, labelKeys),
-		counters: map[string]*counter{},
-		interval: interval,
+		metricVec: newMetricVec("counter", desc, interval, opt.VecOpts, func(labels map[string]string) vecChild {
+			return &counter{
+				mode:    opt.Mode,
+				self:    metrics.NewCounter(),
+				labels:  labels,
+				Desc:    &Desc{step: step},
+				touched: newTouched(opt.TTL),
+			}
+		}),
 	}
 }
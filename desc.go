@@ -22,6 +22,26 @@ func BuildFQName(namespace, subsystem, name string) string {
 	return name
 }
 
+// invalidLabelChars are not allowed in the label keys and values since they break the falcon tags string.
+const invalidLabelChars = ",="
+
+func checkLabelKey(k string) error {
+	if k == "" {
+		return fmt.Errorf("label key should not be empty")
+	}
+	if strings.ContainsAny(k, invalidLabelChars) {
+		return fmt.Errorf("label key %q should not contain any of %q", k, invalidLabelChars)
+	}
+	return nil
+}
+
+func checkLabelValue(v string) error {
+	if strings.ContainsAny(v, invalidLabelChars) {
+		return fmt.Errorf("label value %q should not contain any of %q", v, invalidLabelChars)
+	}
+	return nil
+}
+
 // Desc is the descriptor used by every Metric.
 type Desc struct {
 	// fqName has been built from Namespace, Subsystem, and Name.
@@ -62,5 +82,18 @@ func NewDesc(fqName, help string, step uint32, labelKeys []string) *Desc {
 	}
 
 	d.step = step
+
+	seen := make(map[string]bool)
+	for _, k := range labelKeys {
+		if err := checkLabelKey(k); err != nil {
+			d.err = fmt.Errorf("%s: %v", fqName, err)
+			return d
+		}
+		if seen[k] {
+			d.err = fmt.Errorf("%s: duplicated label key %q", fqName, k)
+			return d
+		}
+		seen[k] = true
+	}
 	return d
 }
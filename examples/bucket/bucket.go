@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/chenjiandongx/aura/reporter"
+)
+
+var (
+	latencyA = aura.NewBucketHistogram(
+		"host.latencyA",
+		"example:latencyA",
+		15,
+		15*time.Second,
+		nil,
+	)
+
+	latencyB = aura.NewBucketHistogramVec(
+		"host.latencyB",
+		"example:latencyB",
+		15,
+		15*time.Second,
+		[]string{"url"},
+		&aura.BucketHistogramOpts{
+			Buckets: aura.ExponentialBuckets(0.001, 2, 10),
+		},
+	)
+)
+
+func main() {
+	registry := aura.NewRegistry(nil)
+	registry.MustRegister(latencyA, latencyB)
+
+	go func() {
+		for range time.Tick(200 * time.Millisecond) {
+			latencyA.Observe(rand.Float64())
+			latencyB.WithLabelValues("/api/index").Observe(rand.Float64() / 10)
+		}
+	}()
+
+	registry.AddReporter(reporter.DefaultStreamReporter)
+
+	go registry.Serve("localhost:9099")
+	registry.Run(context.Background())
+}
@@ -1,6 +1,11 @@
 package main
 
 import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/chenjiandongx/aura"
@@ -53,6 +58,16 @@ func main() {
 	// Optional: Serve will run a HTTP server which exports more information about collector itself.
 	go registry.Serve("localhost:9099")
 
-	// (5) run forever to collect metrics you declared.
-	registry.Run()
+	// (5) run until SIGINT/SIGTERM is received, the pending metrics will be flushed before exiting.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		cancel()
+	}()
+
+	if err := registry.Run(ctx); err != nil {
+		log.Printf("failed to shutdown registry: %v", err)
+	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"time"
 
 	"github.com/chenjiandongx/aura"
@@ -64,5 +65,5 @@ func main() {
 	registry.AddReporter(reporter.DefaultStreamReporter)
 
 	go registry.Serve("127.0.0.1:9099")
-	registry.Run()
+	registry.Run(context.Background())
 }
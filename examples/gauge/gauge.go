@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"time"
 
 	"github.com/chenjiandongx/aura"
@@ -58,5 +59,5 @@ func main() {
 	}()
 
 	go registry.Serve("127.0.0.1:9099")
-	registry.Run()
+	registry.Run(context.Background())
 }
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/chenjiandongx/aura/collectors/host"
+	"github.com/chenjiandongx/aura/reporter"
+)
+
+func main() {
+	registry := aura.NewRegistry(nil)
+	// cpu.idle, load.1min, mem.memfree.percent, net.if.in.bytes, df.bytes.free.percent ...
+	registry.MustRegister(host.NewCollectors(60, 60*time.Second)...)
+
+	// falcon-agent reports the host metrics with the hostname as endpoint.
+	registry.AddProcessor(aura.MustNewRelabeler(
+		&aura.RelabelConfig{TargetLabel: aura.EndpointLabel, Action: aura.RelabelHostname},
+	))
+	registry.AddReporter(reporter.DefaultStreamReporter)
+
+	go registry.Serve("localhost:9099")
+	registry.Run(context.Background())
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/chenjiandongx/aura/reporter"
+)
+
+var (
+	meterA = aura.NewMeter(
+		"host.meterA",
+		"example:meterA",
+		15,
+		15*time.Second,
+		nil,
+	)
+
+	meterB = aura.NewMeterVec(
+		"host.meterB",
+		"example:meterB",
+		15,
+		15*time.Second,
+		[]string{"url"},
+		&aura.MeterOpts{
+			MVTypes: []aura.MeterVType{aura.MeterVTCount, aura.MeterVTRate1, aura.MeterVTRateMean},
+		},
+	)
+)
+
+func main() {
+	registry := aura.NewRegistry(nil)
+	registry.MustRegister(meterA, meterB)
+
+	go func() {
+		for range time.Tick(200 * time.Millisecond) {
+			meterA.Mark(rand.Int63() % 10)
+			meterB.WithLabelValues("/api/index").Mark(1)
+		}
+	}()
+
+	registry.AddReporter(reporter.DefaultStreamReporter)
+
+	go registry.Serve("localhost:9099")
+	registry.Run(context.Background())
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/chenjiandongx/aura/collectors/proc"
+	"github.com/chenjiandongx/aura/reporter"
+)
+
+func main() {
+	registry := aura.NewRegistry(nil)
+	// proc.num, proc.cpu.percent, proc.mem.rss ... labeled by name=nginx/redis/myapp.
+	registry.MustRegister(proc.MustNewCollector(60, 60*time.Second,
+		&proc.Rule{Name: "nginx", Exe: "nginx"},
+		&proc.Rule{Name: "redis", Pidfile: "/var/run/redis.pid"},
+		&proc.Rule{Name: "myapp", Cmdline: `java .*-jar myapp\.jar`},
+	))
+	registry.AddReporter(reporter.DefaultStreamReporter)
+
+	go registry.Serve("localhost:9099")
+	registry.Run(context.Background())
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/chenjiandongx/aura/reporter"
+)
+
+var (
+	// scrape the node_exporter and convert its metrics into the falcon style.
+	// node_cpu_seconds_total{cpu="0",mode="idle"} -> node.node.cpu.seconds.total tags:cpu=0,mode=idle
+	nodeExporter = aura.NewPromScrapeCollector(
+		"http://localhost:9100/metrics",
+		15,
+		15*time.Second,
+		&aura.PromScrapeOpts{
+			Namespace:     "node",
+			Separator:     ".",
+			EndpointLabel: "instance",
+			Endpoint:      "localhost",
+			ConstLabels:   map[string]string{"exporter": "node"},
+			Timeout:       5 * time.Second,
+		},
+	)
+)
+
+func main() {
+	registry := aura.NewRegistry(nil)
+	registry.MustRegister(nodeExporter)
+	registry.AddReporter(reporter.DefaultStreamReporter)
+
+	go registry.Serve("localhost:9099")
+	registry.Run(context.Background())
+}
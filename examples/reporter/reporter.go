@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"time"
 
@@ -46,5 +47,5 @@ func main() {
 	}()
 
 	registry.AddReporter(MyReporter{})
-	registry.Run()
+	registry.Run(context.Background())
 }
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/chenjiandongx/aura/collectors/runtime"
+	"github.com/chenjiandongx/aura/reporter"
+)
+
+func main() {
+	registry := aura.NewRegistry(nil)
+	// myapp.goroutines, myapp.gc.pause.0.99, myapp.heap.inuse, myapp.process.rss ...
+	registry.MustRegister(runtime.NewCollector(60, 60*time.Second, &runtime.Opts{
+		Namespace:   "myapp",
+		Percentiles: []float64{0.5, 0.99},
+	}))
+	registry.AddReporter(reporter.DefaultStreamReporter)
+
+	go registry.Serve("localhost:9099")
+	registry.Run(context.Background())
+}
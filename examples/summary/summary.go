@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/chenjiandongx/aura/reporter"
+)
+
+var (
+	summaryA = aura.NewSummary(
+		"host.summaryA",
+		"example:summaryA",
+		15,
+		15*time.Second,
+		nil,
+	)
+
+	summaryB = aura.NewSummaryVec(
+		"host.summaryB",
+		"example:summaryB",
+		15,
+		15*time.Second,
+		[]string{"url"},
+		&aura.SummaryOpts{
+			Objectives: map[float64]float64{0.5: 0.05, 0.99: 0.001, 0.999: 0.0001},
+			MaxAge:     time.Minute,
+			AgeBuckets: 3,
+		},
+	)
+)
+
+func main() {
+	registry := aura.NewRegistry(nil)
+	registry.MustRegister(summaryA, summaryB)
+
+	go func() {
+		for range time.Tick(10 * time.Millisecond) {
+			summaryA.Observe(rand.NormFloat64())
+			summaryB.WithLabelValues("/api/index").Observe(rand.ExpFloat64())
+		}
+	}()
+
+	registry.AddReporter(reporter.DefaultStreamReporter)
+
+	go registry.Serve("localhost:9099")
+	registry.Run(context.Background())
+}
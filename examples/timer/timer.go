@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"math/rand"
 	"time"
 
@@ -46,5 +47,5 @@ func main() {
 	registry.AddReporter(reporter.DefaultStreamReporter)
 
 	go registry.Serve("localhost:9099")
-	registry.Run()
+	registry.Run(context.Background())
 }
@@ -1,7 +1,6 @@
 package aura
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/rcrowley/go-metrics"
@@ -16,19 +15,27 @@ type Gauge interface {
 	Value() float64
 }
 
+// GaugeOpts specifies the options of a GaugeVec.
+type GaugeOpts struct {
+	VecOpts
+}
+
+// DefaultGaugeOpts holds the GaugeOpts by default case.
+var DefaultGaugeOpts = &GaugeOpts{}
+
 type gauge struct {
 	*Desc
+	touched
 
 	self     metrics.GaugeFloat64
 	labels   map[string]string
 	interval time.Duration
 }
 
+// GaugeVec is a Collector that bundles a set of Gauges which have the same Desc but different label values.
+// It is safe for concurrent use.
 type GaugeVec struct {
-	*Desc
-
-	gauges   map[string]*gauge
-	interval time.Duration
+	*metricVec
 }
 
 func (g *gauge) popMetric(desc *Desc) Metric {
@@ -37,14 +44,19 @@ func (g *gauge) popMetric(desc *Desc) Metric {
 		Metric:    desc.fqName,
 		Step:      desc.step,
 		Value:     g.self.Value(),
-		Type:      CounterValue,
+		Type:      GaugeValue,
 		Labels:    g.labels,
 		Timestamp: time.Now().Unix(),
 	}
 }
 
+func (g *gauge) collect(desc *Desc, ch chan<- Metric) {
+	ch <- g.popMetric(desc)
+}
+
 func (g *gauge) Update(i float64) {
 	g.self.Update(i)
+	g.touch()
 }
 
 func (g *gauge) Value() float64 {
@@ -63,58 +75,70 @@ func (g *gauge) Describe(ch chan<- *Desc) {
 
 // Collect implements aura.Collector.
 func (g *gauge) Collect(ch chan<- Metric) {
-	ch <- g.popMetric(g.Desc)
+	g.collect(g.Desc, ch)
 }
 
-func (gv *GaugeVec) WithLabelValues(lvs ...string) Gauge {
-	if len(gv.Desc.labelKeys) != len(lvs) {
-		panic(fmt.Sprintf("gauge(%s): expected %d label values but go %d",
-			gv.Desc.fqName, len(gv.Desc.labelKeys), len(lvs)),
-		)
+// GetMetricWithLabelValues returns the gauge with the label values, the curried ones should not be given.
+// An error is returned if the number of label values is wrong or any value is invalid.
+func (gv *GaugeVec) GetMetricWithLabelValues(lvs ...string) (Gauge, error) {
+	lvs, err := gv.labelValues(lvs)
+	if err != nil {
+		return nil, err
 	}
 
-	return gv.searchGauge(lvs...)
+	return gv.searchGauge(lvs...), nil
 }
 
-func (gv *GaugeVec) With(labels map[string]string) Gauge {
-	for k := range labels {
-		if !gv.Desc.IsKeyIn(k) {
-			panic(fmt.Sprintf("gauge(%s): expected label key: %s, but it dosen't exists", gv.Desc.fqName, k))
-		}
-	}
-
-	lvs := make([]string, 0)
-	for _, key := range gv.Desc.labelKeys {
-		lvs = append(lvs, labels[key])
+// GetMetricWith returns the gauge with the labels, the missing label keys are regarded as empty values.
+// An error is returned if any label key is unknown or curried, or any value is invalid.
+func (gv *GaugeVec) GetMetricWith(labels map[string]string) (Gauge, error) {
+	lvs, err := gv.labelValuesFromMap(labels)
+	if err != nil {
+		return nil, err
 	}
 
-	return gv.searchGauge(lvs...)
+	return gv.searchGauge(lvs...), nil
 }
 
-func (gv *GaugeVec) searchGauge(lvs ...string) Gauge {
-	lbp := makeLabelPairs(gv.Desc.fqName, gv.Desc.labelKeys, lvs)
-	lbm := makeLabelMap(gv.Desc.labelKeys, lvs)
-
-	_, ok := gv.gauges[lbp]
-	if !ok {
-		gv.gauges[lbp] = &gauge{self: metrics.NewGaugeFloat64(), labels: lbm}
+// WithLabelValues works as GetMetricWithLabelValues but panics if an error occurs.
+func (gv *GaugeVec) WithLabelValues(lvs ...string) Gauge {
+	m, err := gv.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		panic(err)
 	}
+	return m
+}
 
-	return gv.gauges[lbp]
+// With works as GetMetricWith but panics if an error occurs.
+func (gv *GaugeVec) With(labels map[string]string) Gauge {
+	m, err := gv.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return m
 }
 
-func (gv *GaugeVec) Describe(ch chan<- *Desc) {
-	ch <- gv.Desc
+func (gv *GaugeVec) searchGauge(lvs ...string) Gauge {
+	return gv.child(lvs).(*gauge)
 }
 
-func (gv *GaugeVec) Interval() time.Duration {
-	return gv.interval
+// CurryWith returns a GaugeVec with the labels bound, the returned one shares the gauges with gv.
+// It returns an error if any label key is unknown or has been curried.
+func (gv *GaugeVec) CurryWith(labels map[string]string) (*GaugeVec, error) {
+	mv, err := gv.curryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &GaugeVec{metricVec: mv}, nil
 }
 
-func (gv *GaugeVec) Collect(ch chan<- Metric) {
-	for _, v := range gv.gauges {
-		ch <- v.popMetric(gv.Desc)
+// MustCurryWith is like CurryWith but panics if an error occurs.
+func (gv *GaugeVec) MustCurryWith(labels map[string]string) *GaugeVec {
+	curried, err := gv.CurryWith(labels)
+	if err != nil {
+		panic(err)
 	}
+	return curried
 }
 
 func NewGauge(fqName, help string, step uint32, interval time.Duration) Gauge {
@@ -126,10 +150,22 @@ func NewGauge(fqName, help string, step uint32, interval time.Duration) Gauge {
 	}
 }
 
-func NewGaugeVec(fqName, help string, step uint32, interval time.Duration, labelKeys []string) *GaugeVec {
+// NewGaugeVec returns a GaugeVec, the DefaultGaugeOpts will be used if opts is not given.
+func NewGaugeVec(fqName, help string, step uint32, interval time.Duration, labelKeys []string, opts ...*GaugeOpts) *GaugeVec {
+	opt := DefaultGaugeOpts
+	if len(opts) > 0 && opts[0] != nil {
+		opt = opts[0]
+	}
+
+	desc := NewDesc(fqName, help, step, labelKeys)
 	return &GaugeVec{
-		Desc:     NewDesc(fqName, help, step, labelKeys),
-		gauges:   map[string]*gauge{},
-		interval: interval,
+		metricVec: newMetricVec("gauge", desc, interval, opt.VecOpts, func(labels map[string]string) vecChild {
+			return &gauge{
+				self:    metrics.NewGaugeFloat64(),
+				labels:  labels,
+				Desc:    &Desc{step: step},
+				touched: newTouched(opt.TTL),
+			}
+		}),
 	}
 }
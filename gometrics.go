@@ -0,0 +1,125 @@
+package aura
+
+import (
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// GoMetricsOpts specifies how the histograms and timers in a go-metrics registry are reported.
+type GoMetricsOpts struct {
+	Histogram *HistogramOpts
+	Timer     *TimerOpts
+	Meter     *MeterOpts
+}
+
+// DefaultGoMetricsOpts holds the GoMetricsOpts by default case.
+var DefaultGoMetricsOpts = &GoMetricsOpts{
+	Histogram: DefaultHistogramOpts,
+	Timer:     DefaultTimerOpts,
+	Meter: &MeterOpts{
+		MVTypes: []MeterVType{MeterVTCount, MeterVTRate1, MeterVTRate5, MeterVTRate15, MeterVTRateMean},
+	},
+}
+
+// GoMetricsCollector bridges a go-metrics registry into aura, all the metrics in the registry
+// are walked on every collecting.
+type GoMetricsCollector struct {
+	registry  metrics.Registry
+	namespace string
+	step      uint32
+	interval  time.Duration
+	opts      *GoMetricsOpts
+
+	// counters keeps the previous count for calculating the rate.
+	counters map[string]*counter
+}
+
+func (c *GoMetricsCollector) desc(name string) *Desc {
+	return NewDesc(BuildFQName(c.namespace, "", name), "", c.step, nil)
+}
+
+func (c *GoMetricsCollector) popGauge(desc *Desc, v interface{}) Metric {
+	return Metric{
+		Metric:    desc.fqName,
+		Step:      desc.step,
+		Value:     v,
+		Type:      GaugeValue,
+		Labels:    map[string]string{},
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// Interval implements aura.Collector.
+func (c *GoMetricsCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Describe implements aura.Collector.
+func (c *GoMetricsCollector) Describe(ch chan<- *Desc) {
+	c.registry.Each(func(name string, i interface{}) {
+		switch i.(type) {
+		case metrics.Counter, metrics.Gauge, metrics.GaugeFloat64, metrics.Meter, metrics.Histogram, metrics.Timer:
+			ch <- c.desc(name)
+		}
+	})
+}
+
+// Collect implements aura.Collector.
+func (c *GoMetricsCollector) Collect(ch chan<- Metric) {
+	c.registry.Each(func(name string, i interface{}) {
+		desc := c.desc(name)
+
+		switch m := i.(type) {
+		case metrics.Counter:
+			cnt, ok := c.counters[name]
+			if !ok || cnt.self != m {
+				cnt = &counter{Desc: desc, self: m, labels: map[string]string{}}
+				c.counters[name] = cnt
+			}
+			ch <- cnt.popMetric(desc)
+		case metrics.Gauge:
+			ch <- c.popGauge(desc, m.Value())
+		case metrics.GaugeFloat64:
+			ch <- c.popGauge(desc, m.Value())
+		case metrics.Meter:
+			mt := &meter{Desc: desc, self: m.Snapshot(), labels: map[string]string{}, opts: c.opts.Meter}
+			mt.collect(desc, ch)
+		case metrics.Histogram:
+			h := &histogram{Desc: desc, self: m.Snapshot(), labels: map[string]string{}, opts: c.opts.Histogram}
+			h.collect(desc, ch)
+		case metrics.Timer:
+			t := &timer{Desc: desc, self: m.Snapshot(), labels: map[string]string{}, opts: c.opts.Timer}
+			t.collect(desc, ch)
+		}
+	})
+}
+
+// NewGoMetricsCollector returns a Collector which reports all the metrics in the go-metrics registry.
+// The metrics.DefaultRegistry will be used if the registry is nil.
+func NewGoMetricsCollector(registry metrics.Registry, namespace string, step uint32, interval time.Duration, opts *GoMetricsOpts) *GoMetricsCollector {
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+	if opts == nil {
+		opts = DefaultGoMetricsOpts
+	}
+	if opts.Histogram == nil {
+		opts.Histogram = DefaultHistogramOpts
+	}
+	if opts.Timer == nil {
+		opts.Timer = DefaultTimerOpts
+	}
+	if opts.Meter == nil {
+		opts.Meter = DefaultGoMetricsOpts.Meter
+	}
+
+	return &GoMetricsCollector{
+		registry:  registry,
+		namespace: namespace,
+		step:      step,
+		interval:  interval,
+		opts:      opts,
+		counters:  map[string]*counter{},
+	}
+}
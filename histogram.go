@@ -19,10 +19,18 @@ type Histogram interface {
 type HistogramOpts struct {
 	HVTypes     []HistogramVType
 	Percentiles []float64
+
+	// Sample specifies the sampling strategy, the DefaultSampleOpts will be used if it is nil.
+	Sample *SampleOpts
+
+	// Aggregate only works for the HistogramVec, no aggregated series are reported if it is nil.
+	Aggregate *AggregateOpts
+
+	// VecOpts only works for the HistogramVec.
+	VecOpts
 }
 
 var (
-	defaultSample        = metrics.NewExpDecaySample(1028, 0.015)
 	DefaultHistogramOpts = &HistogramOpts{
 		HVTypes:     []HistogramVType{HistogramVTMin, HistogramVTMax, HistogramVTMean},
 		Percentiles: nil,
@@ -43,67 +51,92 @@ const (
 
 type histogram struct {
 	*Desc
+	touched
 
 	opts     *HistogramOpts
 	self     metrics.Histogram
+	sketch   *Sketch
 	labels   map[string]string
 	interval time.Duration
 }
 
+// HistogramVec is a Collector that bundles a set of Histograms which have the same Desc but different label values.
+// It is safe for concurrent use.
 type HistogramVec struct {
-	*Desc
+	*metricVec
 
-	opts       *HistogramOpts
-	histograms map[string]*histogram
-	interval   time.Duration
+	opts *HistogramOpts
 }
 
-func (h *histogram) switchValues(v HistogramVType) interface{} {
+func (h *histogram) switchValues(d distribution, v HistogramVType) interface{} {
 	switch v {
 	case HistogramVTMin:
-		return h.self.Mean()
+		return d.Min()
 	case HistogramVTMax:
-		return h.self.Max()
+		return d.Max()
 	case HistogramVTMean:
-		return h.self.Mean()
+		return d.Mean()
 	case HistogramVTCount:
-		return h.self.Count()
+		return d.Count()
 	case HistogramVTSum:
-		return h.self.Sum()
+		return d.Sum()
 	case HistogramVTStdDev:
-		return h.self.StdDev()
+		return d.StdDev()
 	case HistogramVTVariance:
-		return h.self.Variance()
+		return d.Variance()
 	}
 	return nil
 }
 
-func (h *histogram) popMetricWithHVT(desc *Desc, hvt HistogramVType) Metric {
+func (h *histogram) popMetricWithHVT(desc *Desc, d distribution, hvt HistogramVType) Metric {
 	return Metric{
 		Endpoint:  h.labels["endpoint"],
 		Metric:    fmt.Sprintf("%s.%s", desc.fqName, hvt),
 		Step:      desc.step,
-		Value:     h.switchValues(hvt),
+		Value:     h.switchValues(d, hvt),
 		Type:      GaugeValue,
 		Labels:    h.labels,
 		Timestamp: time.Now().Unix(),
 	}
 }
 
-func (h *histogram) popMetricWithPer(desc *Desc, per float64) Metric {
+func (h *histogram) popMetricWithPer(desc *Desc, d distribution, per float64) Metric {
 	return Metric{
 		Endpoint:  h.labels["endpoint"],
 		Metric:    fmt.Sprintf("%s.%.2f", desc.fqName, per),
 		Step:      desc.step,
-		Value:     h.self.Percentile(per),
+		Value:     d.Percentile(per),
 		Type:      GaugeValue,
 		Labels:    h.labels,
 		Timestamp: time.Now().Unix(),
 	}
 }
 
+// collect reports the values of a snapshot of the sample, so they are consistent with each other.
+func (h *histogram) collect(desc *Desc, ch chan<- Metric) {
+	h.report(desc, snapshotSample(h.self.Sample()), ch)
+}
+
+func (h *histogram) report(desc *Desc, d distribution, ch chan<- Metric) {
+	for _, hvt := range h.opts.HVTypes {
+		ch <- h.popMetricWithHVT(desc, d, hvt)
+	}
+
+	for _, per := range h.opts.Percentiles {
+		ch <- h.popMetricWithPer(desc, d, per)
+	}
+}
+
+func (h *histogram) takeSketch() sketchedChild {
+	return sketchedChild{labels: h.labels, sketch: h.sketch.take()}
+}
+
 func (h *histogram) Observe(i int64) {
 	h.self.Update(i)
+	if h.sketch != nil {
+		h.sketch.Add(float64(i))
+	}
+	h.touch()
 }
 
 // Interval implements aura.Collector.
@@ -118,74 +151,84 @@ func (h *histogram) Describe(ch chan<- *Desc) {
 
 // Collect implements aura.Collector.
 func (h *histogram) Collect(ch chan<- Metric) {
-	for _, hvt := range h.opts.HVTypes {
-		ch <- h.popMetricWithHVT(h.Desc, hvt)
-	}
+	h.collect(h.Desc, ch)
+}
 
-	for _, per := range h.opts.Percentiles {
-		ch <- h.popMetricWithPer(h.Desc, per)
+// GetMetricWithLabelValues returns the histogram with the label values, the curried ones should not be given.
+// An error is returned if the number of label values is wrong or any value is invalid.
+func (hv *HistogramVec) GetMetricWithLabelValues(lvs ...string) (Histogram, error) {
+	lvs, err := hv.labelValues(lvs)
+	if err != nil {
+		return nil, err
 	}
+
+	return hv.searchHistogram(lvs...), nil
 }
 
-func (hv *HistogramVec) WithLabelValues(lvs ...string) Histogram {
-	if len(hv.Desc.labelKeys) != len(lvs) {
-		panic(fmt.Sprintf("histogram(%s): expected %d label values but go %d",
-			hv.Desc.fqName, len(hv.Desc.labelKeys), len(lvs)),
-		)
+// GetMetricWith returns the histogram with the labels, the missing label keys are regarded as empty values.
+// An error is returned if any label key is unknown or curried, or any value is invalid.
+func (hv *HistogramVec) GetMetricWith(labels map[string]string) (Histogram, error) {
+	lvs, err := hv.labelValuesFromMap(labels)
+	if err != nil {
+		return nil, err
 	}
 
-	return hv.searchHistogram(lvs...)
+	return hv.searchHistogram(lvs...), nil
 }
 
-func (hv *HistogramVec) With(labels map[string]string) Histogram {
-	for k := range labels {
-		if !hv.Desc.IsKeyIn(k) {
-			panic(fmt.Sprintf("histogram(%s): expected label key: %s, but it dosen't exists", hv.Desc.fqName, k))
-		}
+// WithLabelValues works as GetMetricWithLabelValues but panics if an error occurs.
+func (hv *HistogramVec) WithLabelValues(lvs ...string) Histogram {
+	m, err := hv.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		panic(err)
 	}
+	return m
+}
 
-	lvs := make([]string, 0)
-	for _, key := range hv.Desc.labelKeys {
-		lvs = append(lvs, labels[key])
+// With works as GetMetricWith but panics if an error occurs.
+func (hv *HistogramVec) With(labels map[string]string) Histogram {
+	m, err := hv.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
 	}
-
-	return hv.searchHistogram(lvs...)
+	return m
 }
 
-func (hv *HistogramVec) searchHistogram(lvs ...string) Histogram {
-	lbp := makeLabelPairs(hv.Desc.fqName, hv.Desc.labelKeys, lvs)
-	lbm := makeLabelMap(hv.Desc.labelKeys, lvs)
-
-	_, ok := hv.histograms[lbp]
-	if !ok {
-		hv.histograms[lbp] = &histogram{
-			self:   metrics.NewHistogram(defaultSample),
-			labels: lbm,
-			opts:   hv.opts,
-		}
+// Collect implements aura.Collector, the aggregated series are reported after the ones of the children.
+func (hv *HistogramVec) Collect(ch chan<- Metric) {
+	hv.metricVec.Collect(ch)
+	if hv.opts.Aggregate == nil {
+		return
 	}
 
-	return hv.histograms[lbp]
+	desc := aggregatedDesc(hv.Desc)
+	for _, a := range aggregate(hv.Desc, hv.opts.Aggregate, hv.takeSketches()) {
+		h := &histogram{labels: a.labels, opts: hv.opts}
+		h.report(desc, sketchDistribution{a.sketch}, ch)
+	}
 }
 
-func (hv *HistogramVec) Describe(ch chan<- *Desc) {
-	ch <- hv.Desc
+func (hv *HistogramVec) searchHistogram(lvs ...string) Histogram {
+	return hv.child(lvs).(*histogram)
 }
 
-func (hv *HistogramVec) Interval() time.Duration {
-	return hv.interval
+// CurryWith returns a HistogramVec with the labels bound, the returned one shares the histograms with hv.
+// It returns an error if any label key is unknown or has been curried.
+func (hv *HistogramVec) CurryWith(labels map[string]string) (*HistogramVec, error) {
+	mv, err := hv.curryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &HistogramVec{metricVec: mv, opts: hv.opts}, nil
 }
 
-func (hv *HistogramVec) Collect(ch chan<- Metric) {
-	for _, v := range hv.histograms {
-		for _, hvt := range v.opts.HVTypes {
-			ch <- v.popMetricWithHVT(hv.Desc, hvt)
-		}
-
-		for _, per := range v.opts.Percentiles {
-			ch <- v.popMetricWithPer(hv.Desc, per)
-		}
+// MustCurryWith is like CurryWith but panics if an error occurs.
+func (hv *HistogramVec) MustCurryWith(labels map[string]string) *HistogramVec {
+	curried, err := hv.CurryWith(labels)
+	if err != nil {
+		panic(err)
 	}
+	return curried
 }
 
 func NewHistogram(fqName, help string, step uint32, interval time.Duration, opts *HistogramOpts) Histogram {
@@ -195,7 +238,7 @@ func NewHistogram(fqName, help string, step uint32, interval time.Duration, opts
 
 	return &histogram{
 		Desc:     NewDesc(fqName, help, step, nil),
-		self:     metrics.NewHistogram(defaultSample),
+		self:     metrics.NewHistogram(newSample(opts.Sample)),
 		labels:   map[string]string{},
 		interval: interval,
 		opts:     opts,
@@ -207,10 +250,18 @@ func NewHistogramVec(fqName, help string, step uint32, interval time.Duration, l
 		opts = DefaultHistogramOpts
 	}
 
+	desc := NewDesc(fqName, help, step, labelKeys)
+	checkAggregateOpts(desc, opts.Aggregate)
 	return &HistogramVec{
-		Desc:       NewDesc(fqName, help, step, labelKeys),
-		histograms: map[string]*histogram{},
-		interval:   interval,
-		opts:       opts,
+		metricVec: newMetricVec("histogram", desc, interval, opts.VecOpts, func(labels map[string]string) vecChild {
+			return &histogram{
+				self:    metrics.NewHistogram(newSample(opts.Sample)),
+				sketch:  opts.Aggregate.newSketch(),
+				labels:  labels,
+				opts:    opts,
+				touched: newTouched(opts.TTL),
+			}
+		}),
+		opts: opts,
 	}
 }
@@ -1,9 +1,16 @@
 package aura
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
 func (r *Registry) apiHealth(w http.ResponseWriter, req *http.Request) {
@@ -11,10 +18,12 @@ func (r *Registry) apiHealth(w http.ResponseWriter, req *http.Request) {
 }
 
 func (r *Registry) apiMetadata(w http.ResponseWriter, req *http.Request) {
-	mds := make([]*MetaData, 0)
+	r.mtx.RLock()
+	mds := make([]*MetaData, 0, len(r.metadata))
 	for _, md := range r.metadata {
 		mds = append(mds, md)
 	}
+	r.mtx.RUnlock()
 
 	bs, err := json.Marshal(mds)
 	if err != nil {
@@ -25,15 +34,35 @@ func (r *Registry) apiMetadata(w http.ResponseWriter, req *http.Request) {
 }
 
 func (r *Registry) apiStats(w http.ResponseWriter, req *http.Request) {
+	type ReporterStats struct {
+		Reporter string `json:"reporter"`
+		ChanCap  int    `json:"chanCap"`
+		ChanLen  int    `json:"chanLen"`
+		Dropped  int64  `json:"dropped"`
+	}
+
 	type Stats struct {
-		MetricsChanCap int `json:"metricsChanCap"`
-		MetricsChanLen int `json:"metricsChanLen"`
+		MetricsChanCap int              `json:"metricsChanCap"`
+		MetricsChanLen int              `json:"metricsChanLen"`
+		Reporters      []*ReporterStats `json:"reporters"`
+		Cardinality    map[string]int   `json:"cardinality"`
 	}
 
 	s := Stats{
 		MetricsChanCap: cap(r.metricChs),
 		MetricsChanLen: len(r.metricChs),
+		Reporters:      make([]*ReporterStats, 0, len(r.reporters)),
+		Cardinality:    r.cardinality.cardinality(),
+	}
+	for _, entry := range r.reporters {
+		s.Reporters = append(s.Reporters, &ReporterStats{
+			Reporter: fmt.Sprintf("%T", entry.reporter),
+			ChanCap:  cap(entry.ch),
+			ChanLen:  len(entry.ch),
+			Dropped:  atomic.LoadInt64(&entry.dropped),
+		})
 	}
+
 	bs, err := json.Marshal(s)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -42,11 +71,167 @@ func (r *Registry) apiStats(w http.ResponseWriter, req *http.Request) {
 	w.Write(bs)
 }
 
+// promName translates the dotted fqName to a valid Prometheus metric or label name.
+func promName(s string) string {
+	buf := make([]byte, 0, len(s)+1)
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch == '_', ch == ':':
+		case ch >= '0' && ch <= '9':
+			if i == 0 {
+				buf = append(buf, '_')
+			}
+		default:
+			ch = '_'
+		}
+		buf = append(buf, ch)
+	}
+	return string(buf)
+}
+
+func promType(t ValueType) string {
+	switch t {
+	case CounterValue:
+		return "counter"
+	case GaugeValue:
+		return "gauge"
+	}
+	return "untyped"
+}
+
+// toFloat64 converts the value of a Metric to float64.
+func toFloat64(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	}
+	return 0, false
+}
+
+func formatPromValue(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// lookupHelp finds the help of a metric, the metrics of histograms or timers are
+// named with suffixes like `.max` or `.0.99`, so the longest registered prefix is used.
+func (r *Registry) lookupHelp(metric string) string {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	for name := metric; name != ""; {
+		if md, ok := r.metadata[name]; ok {
+			return md.Help
+		}
+		idx := strings.LastIndex(name, ".")
+		if idx < 0 {
+			break
+		}
+		name = name[:idx]
+	}
+	return ""
+}
+
+func (r *Registry) apiMetrics(w http.ResponseWriter, req *http.Request) {
+	buf := &bytes.Buffer{}
+	escaper := strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper := strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+	// the fqNames like `a.b` and `a_b` are translated to the same name, so the metrics are grouped by
+	// the translated names and only the first fqName of every group is exported.
+	mets := r.snapshot.metrics()
+	names := make([]string, len(mets))
+	for i, m := range mets {
+		names[i] = promName(m.Metric)
+	}
+	idxs := make([]int, len(mets))
+	for i := range idxs {
+		idxs[i] = i
+	}
+	sort.SliceStable(idxs, func(i, j int) bool {
+		return names[idxs[i]] < names[idxs[j]]
+	})
+
+	lastName, lastMetric := "", ""
+	for _, idx := range idxs {
+		m, name := mets[idx], names[idx]
+		value, ok := toFloat64(m.Value)
+		if !ok {
+			continue
+		}
+
+		if name == lastName && m.Metric != lastMetric {
+			if _, logged := r.promCollisions.LoadOrStore(m.Metric, true); !logged {
+				log.Printf("aura: metric(%s) is not exported since it collides with %s as %s", m.Metric, lastMetric, name)
+			}
+			continue
+		}
+		if name != lastName {
+			if help := r.lookupHelp(m.Metric); help != "" {
+				fmt.Fprintf(buf, "# HELP %s %s\n", name, helpEscaper.Replace(help))
+			}
+			fmt.Fprintf(buf, "# TYPE %s %s\n", name, promType(m.Type))
+			lastName, lastMetric = name, m.Metric
+		}
+
+		labels := make(map[string]string)
+		for k, v := range m.Labels {
+			labels[promName(k)] = v
+		}
+		if _, ok := labels["endpoint"]; !ok && m.Endpoint != "" {
+			labels["endpoint"] = m.Endpoint
+		}
+
+		keys := make([]string, 0, len(labels))
+		for k := range labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, escaper.Replace(labels[k])))
+		}
+
+		buf.WriteString(name)
+		if len(pairs) > 0 {
+			buf.WriteString("{" + strings.Join(pairs, ",") + "}")
+		}
+		fmt.Fprintf(buf, " %s %d\n", formatPromValue(value), m.Timestamp*1000)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
 // Serve run the HTTP server which will exports the collectors infos to the user.
 func (r *Registry) Serve(address string) {
 	http.HandleFunc("/-/health", r.apiHealth)
 	http.HandleFunc("/-/metadata", r.apiMetadata)
 	http.HandleFunc("/-/stats", r.apiStats)
+	http.HandleFunc("/metrics", r.apiMetrics)
 	if err := http.ListenAndServe(address, nil); err != nil {
 		panic(fmt.Sprintf("failed to start http server(%s): %+v", address, err))
 	}
@@ -0,0 +1,238 @@
+package aura
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// Meter counts events to produce exponentially-weighted moving average rates
+// at one-, five-, and fifteen-minutes and a mean rate.
+type Meter interface {
+	Collector
+
+	Mark(int64)
+	Count() int64
+	Rate1() float64
+	Rate5() float64
+	Rate15() float64
+	RateMean() float64
+}
+
+type MeterOpts struct {
+	MVTypes []MeterVType
+
+	// VecOpts only works for the MeterVec.
+	VecOpts
+}
+
+var (
+	DefaultMeterOpts = &MeterOpts{
+		MVTypes: []MeterVType{MeterVTRate1, MeterVTRate5, MeterVTRate15},
+	}
+)
+
+type MeterVType string
+
+const (
+	MeterVTCount    MeterVType = "count"
+	MeterVTRate1    MeterVType = "rate1"
+	MeterVTRate5    MeterVType = "rate5"
+	MeterVTRate15   MeterVType = "rate15"
+	MeterVTRateMean MeterVType = "rateMean"
+)
+
+type meter struct {
+	*Desc
+	touched
+
+	opts     *MeterOpts
+	self     metrics.Meter
+	labels   map[string]string
+	interval time.Duration
+}
+
+// MeterVec is a Collector that bundles a set of Meters which have the same Desc but different label values.
+// It is safe for concurrent use.
+type MeterVec struct {
+	*metricVec
+
+	opts *MeterOpts
+}
+
+func (m *meter) switchValues(v MeterVType) interface{} {
+	switch v {
+	case MeterVTCount:
+		return m.self.Count()
+	case MeterVTRate1:
+		return m.self.Rate1()
+	case MeterVTRate5:
+		return m.self.Rate5()
+	case MeterVTRate15:
+		return m.self.Rate15()
+	case MeterVTRateMean:
+		return m.self.RateMean()
+	}
+	return nil
+}
+
+func (m *meter) popMetricWithMVT(desc *Desc, mvt MeterVType) Metric {
+	return Metric{
+		Endpoint:  m.labels["endpoint"],
+		Metric:    fmt.Sprintf("%s.%s", desc.fqName, mvt),
+		Step:      desc.step,
+		Value:     m.switchValues(mvt),
+		Type:      GaugeValue,
+		Labels:    m.labels,
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+func (m *meter) collect(desc *Desc, ch chan<- Metric) {
+	for _, mvt := range m.opts.MVTypes {
+		ch <- m.popMetricWithMVT(desc, mvt)
+	}
+}
+
+// stop releases the meter from the ticking of the rates.
+func (m *meter) stop() {
+	m.self.Stop()
+}
+
+// Mark records the occurrence of n events.
+func (m *meter) Mark(n int64) {
+	m.self.Mark(n)
+	m.touch()
+}
+
+// Count returns the number of events recorded.
+func (m *meter) Count() int64 {
+	return m.self.Count()
+}
+
+// Rate1 returns the one-minute moving average rate of events per second.
+func (m *meter) Rate1() float64 {
+	return m.self.Rate1()
+}
+
+// Rate5 returns the five-minute moving average rate of events per second.
+func (m *meter) Rate5() float64 {
+	return m.self.Rate5()
+}
+
+// Rate15 returns the fifteen-minute moving average rate of events per second.
+func (m *meter) Rate15() float64 {
+	return m.self.Rate15()
+}
+
+// RateMean returns the meter's mean rate of events per second.
+func (m *meter) RateMean() float64 {
+	return m.self.RateMean()
+}
+
+// Interval implements aura.Collector.
+func (m *meter) Interval() time.Duration {
+	return m.interval
+}
+
+// Describe implements aura.Collector.
+func (m *meter) Describe(ch chan<- *Desc) {
+	ch <- m.Desc
+}
+
+// Collect implements aura.Collector.
+func (m *meter) Collect(ch chan<- Metric) {
+	m.collect(m.Desc, ch)
+}
+
+// GetMetricWithLabelValues returns the meter with the label values, the curried ones should not be given.
+// An error is returned if the number of label values is wrong or any value is invalid.
+func (mv *MeterVec) GetMetricWithLabelValues(lvs ...string) (Meter, error) {
+	lvs, err := mv.labelValues(lvs)
+	if err != nil {
+		return nil, err
+	}
+
+	return mv.searchMeter(lvs...), nil
+}
+
+// GetMetricWith returns the meter with the labels, the missing label keys are regarded as empty values.
+// An error is returned if any label key is unknown or curried, or any value is invalid.
+func (mv *MeterVec) GetMetricWith(labels map[string]string) (Meter, error) {
+	lvs, err := mv.labelValuesFromMap(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return mv.searchMeter(lvs...), nil
+}
+
+// WithLabelValues works as GetMetricWithLabelValues but panics if an error occurs.
+func (mv *MeterVec) WithLabelValues(lvs ...string) Meter {
+	m, err := mv.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// With works as GetMetricWith but panics if an error occurs.
+func (mv *MeterVec) With(labels map[string]string) Meter {
+	m, err := mv.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (mv *MeterVec) searchMeter(lvs ...string) Meter {
+	return mv.child(lvs).(*meter)
+}
+
+// CurryWith returns a MeterVec with the labels bound, the returned one shares the meters with mv.
+// It returns an error if any label key is unknown or has been curried.
+func (mv *MeterVec) CurryWith(labels map[string]string) (*MeterVec, error) {
+	v, err := mv.curryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &MeterVec{metricVec: v, opts: mv.opts}, nil
+}
+
+// MustCurryWith is like CurryWith but panics if an error occurs.
+func (mv *MeterVec) MustCurryWith(labels map[string]string) *MeterVec {
+	curried, err := mv.CurryWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return curried
+}
+
+func NewMeter(fqName, help string, step uint32, interval time.Duration, opts *MeterOpts) Meter {
+	if opts == nil {
+		opts = DefaultMeterOpts
+	}
+
+	return &meter{
+		Desc:     NewDesc(fqName, help, step, nil),
+		self:     metrics.NewMeter(),
+		labels:   map[string]string{},
+		interval: interval,
+		opts:     opts,
+	}
+}
+
+func NewMeterVec(fqName, help string, step uint32, interval time.Duration, labelKeys []string, opts *MeterOpts) *MeterVec {
+	if opts == nil {
+		opts = DefaultMeterOpts
+	}
+
+	desc := NewDesc(fqName, help, step, labelKeys)
+	return &MeterVec{
+		metricVec: newMetricVec("meter", desc, interval, opts.VecOpts, func(labels map[string]string) vecChild {
+			return &meter{self: metrics.NewMeter(), labels: labels, opts: opts, touched: newTouched(opts.TTL)}
+		}),
+		opts: opts,
+	}
+}
@@ -32,11 +32,12 @@ func makeLabelPairs(fqname string, keys, values []string) string {
 	buf := &bytes.Buffer{}
 	buf.WriteString(fqname)
 	for idx, k := range keys {
-		if idx == len(keys)-1 {
-			buf.WriteString(fmt.Sprintf("%s=%s", k, values[idx]))
-			continue
+		if idx > 0 {
+			buf.WriteByte(',')
 		}
-		buf.WriteString(fmt.Sprintf("%s=%s,", k, values[idx]))
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(values[idx])
 	}
 
 	return buf.String()
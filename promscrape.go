@@ -0,0 +1,498 @@
+package aura
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// PromScrapeOpts specifies how the samples exposed by a Prometheus exporter are mapped to aura metrics.
+type PromScrapeOpts struct {
+	// Namespace will be prepended to every metric name.
+	Namespace string
+	// Separator replaces the "_" in the Prometheus metric names. Names are kept as they are if it is empty.
+	Separator string
+	// EndpointLabel is the label whose value becomes the Endpoint of a metric, it is removed from the labels.
+	EndpointLabel string
+	// Endpoint is used when a sample doesn't carry the EndpointLabel.
+	Endpoint string
+	// ConstLabels will be attached to every metric.
+	ConstLabels map[string]string
+	// Timeout limits the time spent on each scraping.
+	Timeout time.Duration
+}
+
+// DefaultPromScrapeOpts holds the PromScrapeOpts by default case.
+var DefaultPromScrapeOpts = &PromScrapeOpts{
+	Separator:     ".",
+	EndpointLabel: "endpoint",
+	Timeout:       5 * time.Second,
+}
+
+const (
+	promTypeCounter   = "counter"
+	promTypeGauge     = "gauge"
+	promTypeHistogram = "histogram"
+	promTypeSummary   = "summary"
+	promTypeUntyped   = "untyped"
+)
+
+type promSample struct {
+	name      string
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+type promFamily struct {
+	name    string
+	help    string
+	typ     string
+	samples []promSample
+}
+
+// PromScrapeCollector turns a Prometheus text exposition endpoint into an aura Collector.
+// Every time it collects, the endpoint will be scraped and all the samples will be converted
+// to the aura metrics. The number of failed scrapings is reported as `<Namespace>.promscrape.failures`,
+// so the collectors of different exporters should have different namespaces.
+type PromScrapeCollector struct {
+	url      string
+	step     uint32
+	interval time.Duration
+	opts     *PromScrapeOpts
+	client   *resty.Client
+
+	failuresDesc *Desc
+	failures     uint64
+	failing      int32
+	warned       int32
+
+	mtx   sync.Mutex
+	descs []*Desc
+}
+
+func (c *PromScrapeCollector) scrape() ([]*promFamily, error) {
+	resp, err := c.client.R().Get(c.url)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("failed to scrape %s: unexpected status code %d", c.url, resp.StatusCode())
+	}
+
+	return parsePromText(bytes.NewReader(resp.Body()))
+}
+
+func (c *PromScrapeCollector) convertName(name string) string {
+	if c.opts.Separator != "" {
+		name = strings.Replace(name, "_", c.opts.Separator, -1)
+	}
+	return BuildFQName(c.opts.Namespace, "", name)
+}
+
+func (c *PromScrapeCollector) joinName(name, suffix string) string {
+	sep := c.opts.Separator
+	if sep == "" {
+		sep = "_"
+	}
+	return name + sep + suffix
+}
+
+func (c *PromScrapeCollector) popMetric(family *promFamily, sample promSample, now int64) Metric {
+	labels := make(map[string]string)
+	for k, v := range c.opts.ConstLabels {
+		labels[k] = v
+	}
+	for k, v := range sample.labels {
+		labels[k] = v
+	}
+
+	name := c.convertName(sample.name)
+	if family.typ == promTypeSummary && sample.name == family.name {
+		if q, ok := labels["quantile"]; ok {
+			name = c.joinName(name, q)
+			delete(labels, "quantile")
+		}
+	}
+
+	endpoint := c.opts.Endpoint
+	if v, ok := labels[c.opts.EndpointLabel]; ok && c.opts.EndpointLabel != "" {
+		endpoint = v
+		delete(labels, c.opts.EndpointLabel)
+	}
+
+	// the buckets, sum and count of histograms and summaries are cumulative as well.
+	valueType := GaugeValue
+	if family.typ == promTypeCounter || sample.name != family.name {
+		valueType = CounterValue
+	}
+
+	timestamp := now
+	if sample.timestamp != 0 {
+		timestamp = sample.timestamp / 1000
+	}
+
+	return Metric{
+		Endpoint:  endpoint,
+		Metric:    name,
+		Step:      c.step,
+		Value:     sample.value,
+		Type:      valueType,
+		Labels:    labels,
+		Timestamp: timestamp,
+	}
+}
+
+// Interval implements aura.Collector.
+func (c *PromScrapeCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// describeFamily returns the descs of all the names converted from the family, e.g. the `_bucket`,
+// `_sum` and `_count` ones of a histogram.
+func (c *PromScrapeCollector) describeFamily(family *promFamily) []*Desc {
+	keys := make(map[string]bool)
+	quantiles := make(map[string]bool)
+	for _, sample := range family.samples {
+		for k, v := range sample.labels {
+			if family.typ == promTypeSummary && k == "quantile" {
+				if sample.name == family.name {
+					quantiles[v] = true
+				}
+				continue
+			}
+			if k == c.opts.EndpointLabel {
+				continue
+			}
+			keys[k] = true
+		}
+	}
+
+	labelKeys := make([]string, 0, len(keys))
+	for k := range keys {
+		labelKeys = append(labelKeys, k)
+	}
+	sort.Strings(labelKeys)
+
+	names := []string{c.convertName(family.name)}
+	switch family.typ {
+	case promTypeHistogram:
+		names = append(names, c.convertName(family.name+"_bucket"))
+		fallthrough
+	case promTypeSummary:
+		names = append(names, c.convertName(family.name+"_sum"), c.convertName(family.name+"_count"))
+	}
+	for q := range quantiles {
+		names = append(names, c.joinName(c.convertName(family.name), q))
+	}
+
+	descs := make([]*Desc, 0, len(names))
+	for _, name := range names {
+		descs = append(descs, NewDesc(name, family.help, c.step, labelKeys))
+	}
+	return descs
+}
+
+// lazyDescs implements aura.lazyDescriber, it returns the descs of the last successful scraping.
+func (c *PromScrapeCollector) lazyDescs() []*Desc {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.descs
+}
+
+// Failures returns the number of failed scrapings.
+func (c *PromScrapeCollector) Failures() uint64 {
+	return atomic.LoadUint64(&c.failures)
+}
+
+// Describe implements aura.Collector. Only the failure counter is described, so registering never
+// depends on the exporter being reachable, the descs of the scraped metrics are filled in by the
+// registry after every collecting.
+func (c *PromScrapeCollector) Describe(ch chan<- *Desc) {
+	ch <- c.failuresDesc
+}
+
+// Collect implements aura.Collector.
+func (c *PromScrapeCollector) Collect(ch chan<- Metric) {
+	now := time.Now().Unix()
+	families, err := c.scrape()
+	if err != nil {
+		n := atomic.AddUint64(&c.failures, 1)
+		// logs only when the exporter turns unavailable, the counter tells how long it lasts.
+		if atomic.CompareAndSwapInt32(&c.failing, 0, 1) {
+			log.Printf("aura: failed to scrape %s (%d failures so far): %v", c.url, n, err)
+		}
+	} else {
+		if atomic.CompareAndSwapInt32(&c.failing, 1, 0) {
+			log.Printf("aura: scraping %s recovered", c.url)
+		}
+
+		var descs []*Desc
+		for _, family := range families {
+			descs = append(descs, c.describeFamily(family)...)
+		}
+		c.mtx.Lock()
+		c.descs = descs
+		c.mtx.Unlock()
+	}
+
+	labels := make(map[string]string, len(c.opts.ConstLabels))
+	for k, v := range c.opts.ConstLabels {
+		labels[k] = v
+	}
+	ch <- Metric{
+		Endpoint:  c.opts.Endpoint,
+		Metric:    c.failuresDesc.fqName,
+		Step:      c.step,
+		Value:     atomic.LoadUint64(&c.failures),
+		Type:      CounterValue,
+		Labels:    labels,
+		Timestamp: now,
+	}
+
+	for _, family := range families {
+		for _, sample := range family.samples {
+			// NaN and Inf can not be accepted by the falcon backend.
+			if math.IsNaN(sample.value) || math.IsInf(sample.value, 0) {
+				continue
+			}
+
+			m := c.popMetric(family, sample, now)
+			if err := checkLabels(m.Labels); err != nil {
+				if atomic.CompareAndSwapInt32(&c.warned, 0, 1) {
+					log.Printf("aura: the samples of %s with invalid labels are skipped, e.g. %s: %v", c.url, sample.name, err)
+				}
+				continue
+			}
+			ch <- m
+		}
+	}
+}
+
+// NewPromScrapeCollector returns a Collector which scrapes the Prometheus metrics from the given url.
+func NewPromScrapeCollector(url string, step uint32, interval time.Duration, opts *PromScrapeOpts) *PromScrapeCollector {
+	if opts == nil {
+		opts = DefaultPromScrapeOpts
+	}
+
+	client := resty.New()
+	if opts.Timeout > 0 {
+		client.SetTimeout(opts.Timeout)
+	}
+
+	c := &PromScrapeCollector{
+		url:      url,
+		step:     step,
+		interval: interval,
+		opts:     opts,
+		client:   client,
+	}
+	c.failuresDesc = NewDesc(c.convertName("promscrape_failures"), fmt.Sprintf("number of failed scrapings of %s", url), step, nil)
+	return c
+}
+
+// parsePromText parses the Prometheus text exposition format into metric families.
+func parsePromText(r io.Reader) ([]*promFamily, error) {
+	families := make([]*promFamily, 0)
+	index := make(map[string]*promFamily)
+
+	getFamily := func(name string) *promFamily {
+		if f, ok := index[name]; ok {
+			return f
+		}
+		f := &promFamily{name: name, typ: promTypeUntyped}
+		index[name] = f
+		families = append(families, f)
+		return f
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(strings.TrimSpace(line[1:]), " ", 3)
+			if len(fields) < 2 {
+				continue
+			}
+
+			switch fields[0] {
+			case "HELP":
+				if len(fields) == 3 {
+					getFamily(fields[1]).help = unescapePromHelp(fields[2])
+				}
+			case "TYPE":
+				if len(fields) == 3 {
+					getFamily(fields[1]).typ = strings.ToLower(strings.TrimSpace(fields[2]))
+				}
+			}
+			continue
+		}
+
+		sample, err := parsePromSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+
+		family, ok := index[sample.name]
+		if !ok {
+			for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+				if !strings.HasSuffix(sample.name, suffix) {
+					continue
+				}
+				f, exists := index[strings.TrimSuffix(sample.name, suffix)]
+				if exists && (f.typ == promTypeHistogram || f.typ == promTypeSummary) {
+					family = f
+					break
+				}
+			}
+		}
+		if family == nil {
+			family = getFamily(sample.name)
+		}
+		family.samples = append(family.samples, sample)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// families which only have the metadata lines make no sense.
+	ret := make([]*promFamily, 0, len(families))
+	for _, f := range families {
+		if len(f.samples) > 0 {
+			ret = append(ret, f)
+		}
+	}
+	return ret, nil
+}
+
+func parsePromSample(line string) (promSample, error) {
+	sample := promSample{labels: map[string]string{}}
+
+	end := strings.IndexAny(line, "{ \t")
+	if end < 0 {
+		return sample, fmt.Errorf("missing value in %q", line)
+	}
+	sample.name = line[:end]
+	rest := line[end:]
+
+	if strings.HasPrefix(rest, "{") {
+		n, err := parsePromLabels(rest[1:], sample.labels)
+		if err != nil {
+			return sample, err
+		}
+		rest = rest[1+n:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return sample, fmt.Errorf("invalid value in %q", line)
+	}
+
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value %q: %v", fields[0], err)
+	}
+	sample.value = v
+
+	if len(fields) == 2 {
+		ts, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return sample, fmt.Errorf("invalid timestamp %q: %v", fields[1], err)
+		}
+		sample.timestamp = ts
+	}
+
+	return sample, nil
+}
+
+// parsePromLabels parses the labels after the "{" and returns the count of bytes consumed including "}".
+func parsePromLabels(s string, labels map[string]string) (int, error) {
+	i := 0
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return 0, fmt.Errorf("unterminated label set")
+		}
+		if s[i] == '}' {
+			return i + 1, nil
+		}
+
+		eq := strings.IndexByte(s[i:], '=')
+		if eq < 0 {
+			return 0, fmt.Errorf("invalid label in %q", s)
+		}
+		key := strings.TrimSpace(s[i : i+eq])
+		i += eq + 1
+
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i >= len(s) || s[i] != '"' {
+			return 0, fmt.Errorf("label value of %q should be quoted", key)
+		}
+		i++
+
+		buf := &bytes.Buffer{}
+		closed := false
+		for ; i < len(s); i++ {
+			ch := s[i]
+			if ch == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					buf.WriteByte('\n')
+				default:
+					buf.WriteByte(s[i])
+				}
+				continue
+			}
+			if ch == '"' {
+				closed = true
+				i++
+				break
+			}
+			buf.WriteByte(ch)
+		}
+		if !closed {
+			return 0, fmt.Errorf("unterminated label value of %q", key)
+		}
+		labels[key] = buf.String()
+	}
+}
+
+func unescapePromHelp(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(s)
+}
+
+// checkLabels validates the labels which will be joined into the falcon tags string.
+func checkLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if err := checkLabelKey(k); err != nil {
+			return err
+		}
+		if err := checkLabelValue(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
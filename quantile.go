@@ -0,0 +1,141 @@
+package aura
+
+import (
+	"math"
+	"sort"
+)
+
+// quantileTarget is a quantile to be estimated with the absolute error of its rank.
+type quantileTarget struct {
+	quantile float64
+	epsilon  float64
+}
+
+// ckmsSample is a tuple of the CKMS summary, width is the difference between the lowest ranks
+// of the sample and the previous one, delta is the difference between its highest and lowest rank.
+type ckmsSample struct {
+	value float64
+	width float64
+	delta float64
+}
+
+// targetedStream estimates the targeted quantiles of a stream in bounded space with the algorithm of
+// Cormode, Korn, Muthukrishnan and Srivastava, "Effective Computation of Biased Quantiles over Data Streams".
+// It is not safe for concurrent use.
+type targetedStream struct {
+	targets []quantileTarget
+	samples []ckmsSample
+	n       float64
+}
+
+func newTargetedStream(targets []quantileTarget) *targetedStream {
+	return &targetedStream{targets: targets}
+}
+
+// invariant returns the maximum allowed width plus delta of a sample at the rank r.
+func (s *targetedStream) invariant(r float64) float64 {
+	m := math.MaxFloat64
+	for _, t := range s.targets {
+		var f float64
+		if t.quantile*s.n <= r {
+			f = 2 * t.epsilon * r / t.quantile
+		} else {
+			f = 2 * t.epsilon * (s.n - r) / (1 - t.quantile)
+		}
+		if f < m {
+			m = f
+		}
+	}
+	return m
+}
+
+// insert merges the sorted values into the stream and compresses it.
+func (s *targetedStream) insert(sorted []float64) {
+	var r float64
+	i := 0
+	for _, v := range sorted {
+		inserted := false
+		for ; i < len(s.samples); i++ {
+			c := s.samples[i]
+			if c.value > v {
+				delta := math.Max(0, math.Floor(s.invariant(r))-1)
+				s.samples = append(s.samples, ckmsSample{})
+				copy(s.samples[i+1:], s.samples[i:])
+				s.samples[i] = ckmsSample{value: v, width: 1, delta: delta}
+				i++
+				inserted = true
+				break
+			}
+			r += c.width
+		}
+		if !inserted {
+			s.samples = append(s.samples, ckmsSample{value: v, width: 1})
+			i++
+		}
+		s.n++
+		r++
+	}
+	s.compress()
+}
+
+// compress merges the adjacent samples as long as the invariant holds.
+func (s *targetedStream) compress() {
+	if len(s.samples) < 2 {
+		return
+	}
+
+	xi := len(s.samples) - 1
+	x := s.samples[xi]
+	r := s.n - 1 - x.width
+	for i := len(s.samples) - 2; i >= 0; i-- {
+		c := s.samples[i]
+		if c.width+x.width+x.delta <= s.invariant(r) {
+			x.width += c.width
+			s.samples[xi] = x
+			copy(s.samples[i:], s.samples[i+1:])
+			s.samples = s.samples[:len(s.samples)-1]
+			xi--
+		} else {
+			x = c
+			xi = i
+		}
+		r -= c.width
+	}
+}
+
+// query returns the estimated value of the quantile, zero if the stream is empty.
+func (s *targetedStream) query(q float64) float64 {
+	if len(s.samples) == 0 {
+		return 0
+	}
+
+	t := math.Ceil(q * s.n)
+	t += math.Ceil(s.invariant(t) / 2)
+	p := s.samples[0]
+	var r float64
+	for _, c := range s.samples[1:] {
+		r += p.width
+		if r+c.width+c.delta > t {
+			return p.value
+		}
+		p = c
+	}
+	return p.value
+}
+
+func (s *targetedStream) reset() {
+	s.samples = s.samples[:0]
+	s.n = 0
+}
+
+// quantileTargets returns the targets of the objectives ordered by the quantiles.
+func quantileTargets(objectives map[float64]float64) []quantileTarget {
+	targets := make([]quantileTarget, 0, len(objectives))
+	for q, e := range objectives {
+		targets = append(targets, quantileTarget{quantile: q, epsilon: e})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].quantile < targets[j].quantile
+	})
+	return targets
+}
@@ -1,8 +1,11 @@
 package aura
 
 import (
+	"context"
 	"fmt"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,13 +13,24 @@ const (
 	// capacity for the channel to collect metrics and descriptors.
 	defaultCapMetricChan = 2500
 	defaultCapDescChan   = 20
+
+	// defaultShutdownTimeout limits the time spent on shutting down when the context of Run is done.
+	defaultShutdownTimeout = 10 * time.Second
 )
 
 // Reporter is in charge of sending metrics collected to the backend you used.
+// The metric channel will be closed when the Registry shuts down.
 type Reporter interface {
 	Report(ch chan Metric)
 }
 
+// Flusher is an optional interface which a Reporter could implement.
+// Flush will be called after the metric channel has been closed and it should
+// block until the pending metrics have been sent or the context is done.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
 // MetaData represents the metrics metadata for the `/-/metadata` API
 type MetaData struct {
 	Metric string `json:"metric"`
@@ -26,26 +40,49 @@ type MetaData struct {
 
 // Registry registers aura collectors, collects their metrics.
 type Registry struct {
-	opts       *RegistryOpts
-	reporter   Reporter
-	mtx        sync.RWMutex
-	collectors []Collector
-	metricChs  chan Metric
-	metadata   map[string]*MetaData
-	stop       chan struct{}
-	exit       chan struct{}
+	opts        *RegistryOpts
+	reporters   []*reporterEntry
+	processors  []Processor
+	mtx         sync.RWMutex
+	collectors  []*collectorEntry
+	metricChs   chan Metric
+	snapshot    *seriesSnapshot
+	cardinality *cardinalityTracker
+	metadata    map[string]*MetaData
+
+	// promCollisions records the fqNames which are not exported by /metrics due to the name collisions.
+	promCollisions sync.Map
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	running  bool
+	quit     chan struct{}
+	drainCtx context.Context
+	fwdDone  chan struct{}
+	done     chan struct{}
+	doneOnce sync.Once
+	doneErr  error
 }
 
-// RegistryOpts specifies the buffer size of metric channel and desc channel.
+// RegistryOpts specifies the buffer size of metric channel and desc channel,
+// and the timeout of shutting down when the context of Run is done.
 type RegistryOpts struct {
-	CapMetricChan int
-	CapDescChan   int
+	CapMetricChan   int
+	CapDescChan     int
+	ShutdownTimeout time.Duration
+
+	// MaxSeriesPerMetric limits the number of series of every metric name, the new series beyond
+	// the limit are folded into the series labeled by OverflowLabelValue, which reports the sum of
+	// their values. One of the series is reserved for the overflow series. Zero means unlimited.
+	MaxSeriesPerMetric int
 }
 
 // DefaultRegistryOpts holds the RegistryOpts by default case.
 var DefaultRegistryOpts = &RegistryOpts{
-	CapMetricChan: defaultCapMetricChan,
-	CapDescChan:   defaultCapDescChan,
+	CapMetricChan:   defaultCapMetricChan,
+	CapDescChan:     defaultCapDescChan,
+	ShutdownTimeout: defaultShutdownTimeout,
 }
 
 // NewRegistry returns a Registry instance for managing the collecting jobs.
@@ -60,26 +97,48 @@ func NewRegistry(opts *RegistryOpts) *Registry {
 	if opts.CapMetricChan < 1 {
 		opts.CapMetricChan = defaultCapMetricChan
 	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = defaultShutdownTimeout
+	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Registry{
-		opts:       opts,
-		reporter:   nil,
-		mtx:        sync.RWMutex{},
-		collectors: []Collector{},
-		metricChs:  make(chan Metric, opts.CapMetricChan),
-		metadata:   map[string]*MetaData{},
-		stop:       make(chan struct{}),
-		exit:       make(chan struct{}),
+		opts:        opts,
+		reporters:   []*reporterEntry{},
+		processors:  []Processor{},
+		mtx:         sync.RWMutex{},
+		collectors:  []*collectorEntry{},
+		metricChs:   make(chan Metric, opts.CapMetricChan),
+		snapshot:    newSeriesSnapshot(),
+		cardinality: newCardinalityTracker(opts.MaxSeriesPerMetric),
+		metadata:    map[string]*MetaData{},
+		ctx:         ctx,
+		cancel:      cancel,
+		quit:        make(chan struct{}),
+		fwdDone:     make(chan struct{}),
+		done:        make(chan struct{}),
 	}
 }
 
-// AddReporter adds the reporter to decide where metrics go forward.
+// reporterEntry holds a reporter and its own metric channel.
+type reporterEntry struct {
+	reporter Reporter
+	ch       chan Metric
+	dropped  int64
+}
+
+// AddReporter adds the reporter to decide where metrics go forward, it should be called before Run.
+// Every reporter receives a copy of each metric through its own buffered channel, the metrics will be
+// dropped for a reporter whose channel is full, so that a slow reporter cannot stall the others.
 func (r *Registry) AddReporter(reporter Reporter) {
-	r.reporter = reporter
+	r.reporters = append(r.reporters, &reporterEntry{
+		reporter: reporter,
+		ch:       make(chan Metric, r.opts.CapMetricChan),
+	})
 }
 
-// Register register a collector and handler all its `metrics desc`.
-func (r *Registry) Register(c Collector) error {
+// describe gathers all the descriptors of a collector.
+func (r *Registry) describe(c Collector) []*Desc {
 	descChan := make(chan *Desc, r.opts.CapDescChan)
 
 	go func() {
@@ -87,23 +146,50 @@ func (r *Registry) Register(c Collector) error {
 		close(descChan)
 	}()
 
+	descs := make([]*Desc, 0)
+	for desc := range descChan {
+		descs = append(descs, desc)
+	}
+	return descs
+}
+
+// AddProcessor adds a processing stage between the collectors and the reporters, e.g. a Relabeler.
+// Processors are applied in the order they are added, it should be called before Run.
+func (r *Registry) AddProcessor(p Processor) {
+	r.processors = append(r.processors, p)
+}
+
+// Register register a collector and handler all its `metrics desc`.
+// If the registry is already running, the collector will be scheduled immediately.
+func (r *Registry) Register(c Collector) error {
+	descs := r.describe(c)
+
 	r.mtx.Lock()
-	defer func() {
-		// Drain channel in case of premature return to not leak a goroutine.
-		for range descChan {
-		}
-		r.mtx.Unlock()
-	}()
+	defer r.mtx.Unlock()
 
-	for desc := range descChan {
+	if r.ctx.Err() != nil {
+		return fmt.Errorf("registry has been shut down")
+	}
+
+	fqNames := make([]string, 0, len(descs))
+	seen := make(map[string]bool)
+	for _, desc := range descs {
 		if desc.err != nil {
 			return desc.err
 		}
 
-		if _, ok := r.metadata[desc.fqName]; ok {
+		if _, ok := r.metadata[desc.fqName]; (ok && r.lazyOwner(desc.fqName) == nil) || seen[desc.fqName] {
 			return fmt.Errorf("duplicated mertric FQName:(%s)", desc.fqName)
 		}
+		seen[desc.fqName] = true
+		fqNames = append(fqNames, desc.fqName)
+	}
 
+	for _, desc := range descs {
+		// the names claimed lazily are taken over by the collector registered.
+		if owner := r.lazyOwner(desc.fqName); owner != nil {
+			owner.dropLazyName(desc.fqName)
+		}
 		r.metadata[desc.fqName] = &MetaData{
 			Metric: desc.fqName,
 			Help:   desc.help,
@@ -111,10 +197,80 @@ func (r *Registry) Register(c Collector) error {
 		}
 	}
 
-	r.collectors = append(r.collectors, c)
+	entry := &collectorEntry{collector: c, fqNames: fqNames}
+	r.collectors = append(r.collectors, entry)
+	if r.running {
+		r.schedule(entry)
+	}
 	return nil
 }
 
+// Unregister unregisters the given collector, or the one which has the same descriptors if the given one
+// has not been registered, its collecting loop will be stopped and its metadata will be removed.
+// It returns whether a collector has been unregistered.
+func (r *Registry) Unregister(c Collector) bool {
+	r.mtx.Lock()
+	ok := r.remove(func(entry *collectorEntry) bool {
+		return sameCollector(entry.collector, c)
+	})
+	r.mtx.Unlock()
+	if ok {
+		return true
+	}
+
+	descs := r.describe(c)
+	if len(descs) == 0 {
+		return false
+	}
+
+	fqNames := make(map[string]bool)
+	for _, desc := range descs {
+		if desc.err != nil {
+			return false
+		}
+		fqNames[desc.fqName] = true
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.remove(func(entry *collectorEntry) bool {
+		return entry.describedBy(fqNames)
+	})
+}
+
+// remove removes the first collector entry matched, r.mtx must be held.
+func (r *Registry) remove(match func(entry *collectorEntry) bool) bool {
+	for idx, entry := range r.collectors {
+		if !match(entry) {
+			continue
+		}
+
+		if entry.cancel != nil {
+			entry.cancel()
+		}
+		for _, name := range entry.fqNames {
+			delete(r.metadata, name)
+		}
+		for _, name := range entry.lazyNames {
+			delete(r.metadata, name)
+		}
+		r.collectors = append(r.collectors[:idx], r.collectors[idx+1:]...)
+		return true
+	}
+	return false
+}
+
+// sameCollector reports whether the collectors are the same one, the collectors of the types
+// which are not comparable are never the same.
+func sameCollector(a, b Collector) bool {
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta == nil || ta != tb || !ta.Comparable() {
+		return false
+	}
+	return a == b
+}
+
 func (r *Registry) MustRegister(cs ...Collector) {
 	for _, c := range cs {
 		if err := r.Register(c); err != nil {
@@ -123,38 +279,316 @@ func (r *Registry) MustRegister(cs ...Collector) {
 	}
 }
 
-func (r *Registry) gather() {
-	for _, collector := range r.collectors {
-		go func(c Collector) {
-			ticker := time.Tick(c.Interval())
+// collectorEntry holds a registered collector and the cancel function of its collecting loop.
+type collectorEntry struct {
+	collector Collector
+	fqNames   []string
+	lazyNames []string
+	cancel    context.CancelFunc
+}
+
+// lazyOwner returns the entry which has claimed the name lazily, r.mtx must be held.
+func (r *Registry) lazyOwner(name string) *collectorEntry {
+	for _, entry := range r.collectors {
+		for _, n := range entry.lazyNames {
+			if n == name {
+				return entry
+			}
+		}
+	}
+	return nil
+}
+
+func (e *collectorEntry) dropLazyName(name string) {
+	for i, n := range e.lazyNames {
+		if n == name {
+			e.lazyNames = append(e.lazyNames[:i], e.lazyNames[i+1:]...)
+			return
+		}
+	}
+}
+
+// lazyDescriber is implemented by the collectors whose descs are only known after collecting,
+// e.g. the PromScrapeCollector. Their metadata is filled in after every collecting.
+type lazyDescriber interface {
+	lazyDescs() []*Desc
+}
+
+func (e *collectorEntry) describedBy(fqNames map[string]bool) bool {
+	if len(e.fqNames) != len(fqNames) {
+		return false
+	}
+	for _, name := range e.fqNames {
+		if !fqNames[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// schedule starts the collecting loop of a collector, r.mtx must be held.
+func (r *Registry) schedule(entry *collectorEntry) {
+	ctx, cancel := context.WithCancel(r.ctx)
+	entry.cancel = cancel
+
+	r.wg.Add(1)
+	go r.collect(ctx, entry)
+}
+
+// gather starts the forwarding and all the collecting loops.
+func (r *Registry) gather() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
 
-			time.Sleep(2 * time.Second)
+	if r.ctx.Err() != nil {
+		return fmt.Errorf("registry has been shut down")
+	}
+	if r.running {
+		return fmt.Errorf("registry is already running")
+	}
+
+	r.running = true
+	go r.forward()
+	for _, entry := range r.collectors {
+		r.schedule(entry)
+	}
+	return nil
+}
+
+// collect runs the collecting loop of a collector until the context is done.
+func (r *Registry) collect(ctx context.Context, entry *collectorEntry) {
+	defer r.wg.Done()
+
+	c := entry.collector
+	var ticker <-chan time.Time
+	if c.Interval() > 0 {
+		t := time.NewTicker(c.Interval())
+		defer t.Stop()
+		ticker = t.C
+	}
+
+	select {
+	case <-time.After(2 * time.Second):
+	case <-ctx.Done():
+		return
+	}
+	c.Collect(r.metricChs)
+	r.describeLazily(ctx, entry)
+
+	for {
+		select {
+		case <-ticker:
 			c.Collect(r.metricChs)
+			r.describeLazily(ctx, entry)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// describeLazily adds the metadata of the lazy descs which are not known yet, the names already
+// described by other collectors are left as they are.
+func (r *Registry) describeLazily(ctx context.Context, entry *collectorEntry) {
+	d, ok := entry.collector.(lazyDescriber)
+	if !ok {
+		return
+	}
+	descs := d.lazyDescs()
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	// the entry may have been unregistered while collecting.
+	if ctx.Err() != nil {
+		return
+	}
+	for _, desc := range descs {
+		if desc.err != nil {
+			continue
+		}
+		if _, ok := r.metadata[desc.fqName]; ok {
+			continue
+		}
+		r.metadata[desc.fqName] = &MetaData{
+			Metric: desc.fqName,
+			Help:   desc.help,
+			Step:   desc.step,
+		}
+		entry.lazyNames = append(entry.lazyNames, desc.fqName)
+	}
+}
 
+// process runs the processors and records the metric, it returns false if the metric is dropped.
+func (r *Registry) process(m *Metric) bool {
+	for _, p := range r.processors {
+		if !p.Process(m) {
+			return false
+		}
+	}
+
+	key := r.cardinality.track(m)
+	r.snapshot.update(key, *m)
+	return true
+}
+
+// handle dispatches the metric to the reporters, it is dropped for the reporters whose channels are full.
+func (r *Registry) handle(m Metric) {
+	if !r.process(&m) {
+		return
+	}
+
+	for _, entry := range r.reporters {
+		select {
+		case entry.ch <- m:
+		default:
+			atomic.AddInt64(&entry.dropped, 1)
+		}
+	}
+}
+
+// drain dispatches the metric left at shutdown, it waits for the full reporter channels until the ctx is done.
+func (r *Registry) drain(ctx context.Context, m Metric) {
+	if !r.process(&m) {
+		return
+	}
+
+	for _, entry := range r.reporters {
+		// select picks a random ready case, so the channel is tried first in case ctx is already done.
+		select {
+		case entry.ch <- m:
+			continue
+		default:
+		}
+
+		select {
+		case entry.ch <- m:
+		case <-ctx.Done():
+			atomic.AddInt64(&entry.dropped, 1)
+		}
+	}
+}
+
+// forward records the latest value of the metrics collected and dispatches them to the reporters.
+// The metrics left in the buffer will be drained before the reporter channels are closed.
+func (r *Registry) forward() {
+	defer close(r.fwdDone)
+	defer func() {
+		for _, entry := range r.reporters {
+			close(entry.ch)
+		}
+	}()
+
+	for {
+		select {
+		case m := <-r.metricChs:
+			r.handle(m)
+		case <-r.quit:
 			for {
 				select {
-				case <-ticker:
-					c.Collect(r.metricChs)
-				case <-r.stop:
+				case m := <-r.metricChs:
+					r.drain(r.drainCtx, m)
+				default:
 					return
 				}
 			}
-		}(collector)
+		}
 	}
 }
 
-func (r *Registry) Run() {
-	if r.reporter == nil {
+// Run starts collecting metrics and blocks until the context is done or Shutdown is called.
+// When the context is done, the registry will be shut down within the RegistryOpts.ShutdownTimeout.
+func (r *Registry) Run(ctx context.Context) error {
+	if len(r.reporters) == 0 {
 		panic("reporter cannot be nil")
 	}
-	r.gather()
-	r.reporter.Report(r.metricChs)
-	<-r.exit
+
+	if err := r.gather(); err != nil {
+		return err
+	}
+	// custom reporters may block in Report until the metric channel is closed.
+	for _, entry := range r.reporters {
+		go entry.reporter.Report(entry.ch)
+	}
+
+	select {
+	case <-ctx.Done():
+		sctx, cancel := context.WithTimeout(context.Background(), r.opts.ShutdownTimeout)
+		defer cancel()
+		return r.Shutdown(sctx)
+	case <-r.done:
+		return r.doneErr
+	}
 }
 
-func (r *Registry) Stop() {
-	for i := 0; i < len(r.collectors); i++ {
-		r.stop <- struct{}{}
+// Shutdown gracefully shuts down the registry. It stops the collecting loops, waits for the in-flight
+// collecting, drains the metrics buffered and lets the reporters flush their pending metrics.
+// If the context is done before all of those finished, the context's error will be returned.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.doneOnce.Do(func() {
+		// no more collectors could be scheduled once the context is canceled.
+		r.mtx.Lock()
+		running := r.running
+		r.cancel()
+		r.mtx.Unlock()
+
+		if running {
+			r.doneErr = r.shutdown(ctx)
+		}
+		close(r.done)
+	})
+	return r.doneErr
+}
+
+func (r *Registry) shutdown(ctx context.Context) error {
+	collected := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(collected)
+	}()
+
+	var err error
+	select {
+	case <-collected:
+	case <-ctx.Done():
+		err = fmt.Errorf("timeout waiting for collectors: %v", ctx.Err())
+	}
+
+	// drainCtx is published to the forwarding loop by closing the quit channel.
+	r.drainCtx = ctx
+	close(r.quit)
+	select {
+	case <-r.fwdDone:
+	case <-ctx.Done():
+		return fmt.Errorf("timeout draining metrics: %v", ctx.Err())
 	}
-	r.exit <- struct{}{}
+
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	for _, entry := range r.reporters {
+		flusher, ok := entry.reporter.(Flusher)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(f Flusher) {
+			defer wg.Done()
+			if ferr := f.Flush(ctx); ferr != nil {
+				mtx.Lock()
+				if err == nil {
+					err = ferr
+				}
+				mtx.Unlock()
+			}
+		}(flusher)
+	}
+	wg.Wait()
+	return err
+}
+
+// Stop shuts down the registry without a deadline.
+//
+// Deprecated: use Shutdown instead.
+func (r *Registry) Stop() {
+	r.Shutdown(context.Background())
 }
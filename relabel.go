@@ -0,0 +1,190 @@
+package aura
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Processor processes the metrics between the collectors and the reporters.
+// A metric will be dropped if Process returns false.
+type Processor interface {
+	Process(m *Metric) bool
+}
+
+// RelabelAction is the action to be performed on the relabeling.
+type RelabelAction string
+
+const (
+	// RelabelReplace sets TargetLabel to Replacement if the concatenated SourceLabels matches Regex.
+	RelabelReplace RelabelAction = "replace"
+	// RelabelKeep drops the metrics whose concatenated SourceLabels doesn't match Regex.
+	RelabelKeep RelabelAction = "keep"
+	// RelabelDrop drops the metrics whose concatenated SourceLabels matches Regex.
+	RelabelDrop RelabelAction = "drop"
+	// RelabelLabelDrop removes the labels whose name matches Regex.
+	RelabelLabelDrop RelabelAction = "labeldrop"
+	// RelabelLabelKeep removes the labels whose name doesn't match Regex.
+	RelabelLabelKeep RelabelAction = "labelkeep"
+	// RelabelHostname sets TargetLabel to the hostname if it is empty.
+	RelabelHostname RelabelAction = "hostname"
+)
+
+const (
+	// MetricNameLabel is the pseudo label of the metric name on the relabeling.
+	MetricNameLabel = "__name__"
+	// EndpointLabel is the pseudo label of the metric endpoint on the relabeling.
+	EndpointLabel = "__endpoint__"
+)
+
+// RelabelConfig is the configuration of a relabeling rule, it works like the `relabel_configs`
+// of Prometheus. The metric name and endpoint can be accessed by the MetricNameLabel and EndpointLabel.
+type RelabelConfig struct {
+	// SourceLabels are concatenated with Separator and matched against Regex.
+	SourceLabels []string
+	// Separator is ";" by default.
+	Separator string
+	// Regex is "(.*)" by default, it is fully anchored.
+	Regex string
+	// TargetLabel is the label to be written on RelabelReplace and RelabelHostname.
+	TargetLabel string
+	// Replacement supports the regex capture groups like "$1", it is "$1" by default.
+	Replacement string
+	// Action is RelabelReplace by default.
+	Action RelabelAction
+
+	regex *regexp.Regexp
+}
+
+func (c *RelabelConfig) compile() error {
+	if c.Separator == "" {
+		c.Separator = ";"
+	}
+	if c.Regex == "" {
+		c.Regex = "(.*)"
+	}
+	if c.Replacement == "" {
+		c.Replacement = "$1"
+	}
+	if c.Action == "" {
+		c.Action = RelabelReplace
+	}
+
+	regex, err := regexp.Compile("^(?:" + c.Regex + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid relabel regex %q: %v", c.Regex, err)
+	}
+	c.regex = regex
+
+	switch c.Action {
+	case RelabelReplace, RelabelHostname:
+		if c.TargetLabel == "" {
+			return fmt.Errorf("relabel action %q requires the target label", c.Action)
+		}
+	case RelabelKeep, RelabelDrop, RelabelLabelDrop, RelabelLabelKeep:
+	default:
+		return fmt.Errorf("unknown relabel action %q", c.Action)
+	}
+	return nil
+}
+
+func isPseudoLabel(k string) bool {
+	return k == MetricNameLabel || k == EndpointLabel
+}
+
+// apply performs the relabeling on the labels, returns false if the metric should be dropped.
+func (c *RelabelConfig) apply(labels map[string]string, hostname string) bool {
+	values := make([]string, 0, len(c.SourceLabels))
+	for _, k := range c.SourceLabels {
+		values = append(values, labels[k])
+	}
+	value := strings.Join(values, c.Separator)
+
+	switch c.Action {
+	case RelabelKeep:
+		return c.regex.MatchString(value)
+	case RelabelDrop:
+		return !c.regex.MatchString(value)
+	case RelabelReplace:
+		indexes := c.regex.FindStringSubmatchIndex(value)
+		if indexes == nil {
+			return true
+		}
+		target := string(c.regex.ExpandString(nil, c.Replacement, value, indexes))
+		if target == "" {
+			delete(labels, c.TargetLabel)
+			return true
+		}
+		labels[c.TargetLabel] = target
+	case RelabelLabelDrop:
+		for k := range labels {
+			if !isPseudoLabel(k) && c.regex.MatchString(k) {
+				delete(labels, k)
+			}
+		}
+	case RelabelLabelKeep:
+		for k := range labels {
+			if !isPseudoLabel(k) && !c.regex.MatchString(k) {
+				delete(labels, k)
+			}
+		}
+	case RelabelHostname:
+		if labels[c.TargetLabel] == "" {
+			labels[c.TargetLabel] = hostname
+		}
+	}
+	return true
+}
+
+// Relabeler is a Processor which applies the relabeling rules in order.
+type Relabeler struct {
+	configs  []*RelabelConfig
+	hostname string
+}
+
+// NewRelabeler returns a Relabeler with the rules given.
+func NewRelabeler(configs ...*RelabelConfig) (*Relabeler, error) {
+	for _, c := range configs {
+		if err := c.compile(); err != nil {
+			return nil, err
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	return &Relabeler{configs: configs, hostname: hostname}, nil
+}
+
+// MustNewRelabeler is like NewRelabeler but panics if the rules are invalid.
+func MustNewRelabeler(configs ...*RelabelConfig) *Relabeler {
+	r, err := NewRelabeler(configs...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Process implements aura.Processor.
+func (r *Relabeler) Process(m *Metric) bool {
+	// the labels map may be shared with the collector, it should not be modified in place.
+	labels := make(map[string]string, len(m.Labels)+2)
+	for k, v := range m.Labels {
+		labels[k] = v
+	}
+	labels[MetricNameLabel] = m.Metric
+	labels[EndpointLabel] = m.Endpoint
+
+	for _, c := range r.configs {
+		if !c.apply(labels, r.hostname) {
+			return false
+		}
+	}
+
+	m.Metric = labels[MetricNameLabel]
+	m.Endpoint = labels[EndpointLabel]
+	delete(labels, MetricNameLabel)
+	delete(labels, EndpointLabel)
+	m.Labels = labels
+
+	return m.Metric != ""
+}
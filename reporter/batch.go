@@ -0,0 +1,131 @@
+package reporter
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+)
+
+// batcher gathers the metrics from the channel into batches for the reporters.
+type batcher struct {
+	wg sync.WaitGroup
+}
+
+// run starts the workers which hand the batches over to the send function whenever a batch
+// is full or the ticker ticks. The workers send the pending batches and exit once the channel is closed.
+// The errors are left to the send function to deal with, e.g. buffering the batch or calling a hook.
+func (b *batcher) run(ch chan aura.Metric, batch, concurrency int, ticker <-chan time.Time, send func([]aura.Metric) error) {
+	for i := 0; i < concurrency; i++ {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+
+			ms := make([]aura.Metric, 0)
+			for {
+				select {
+				case metric, ok := <-ch:
+					if !ok {
+						if len(ms) > 0 {
+							send(ms)
+						}
+						return
+					}
+
+					if len(ms) >= batch {
+						send(ms)
+						ms = make([]aura.Metric, 0)
+					}
+					ms = append(ms, metric)
+				case <-ticker:
+					if len(ms) == 0 {
+						continue
+					}
+					send(ms)
+					ms = make([]aura.Metric, 0)
+				}
+			}
+		}()
+	}
+}
+
+// wait blocks until all the workers have exited or the context is done.
+func (b *batcher) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// BatchStats holds the count of items sent, failed and rejected by a reporter, the rejected ones
+// have been received by the backend but are reported as invalid.
+type BatchStats struct {
+	Sent     int64 `json:"sent"`
+	Failed   int64 `json:"failed"`
+	Rejected int64 `json:"rejected"`
+}
+
+// batchCounter counts the results of the batches sent by a reporter.
+type batchCounter struct {
+	sent     int64
+	failed   int64
+	rejected int64
+}
+
+// rejectedItems returns the number of the items rejected by the backend.
+func rejectedItems(err error) int {
+	switch e := err.(type) {
+	case *NightingaleRejectedError:
+		return len(e.Messages)
+	case *TransferInvalidError:
+		return e.Response.Invalid
+	}
+	return 0
+}
+
+// count counts the batch sent with err, which is handed over to the onError if it is set.
+// The rejections are logged otherwise, since sending the batch again makes no sense.
+func (c *batchCounter) count(mets []aura.Metric, err error, onError func([]aura.Metric, error)) {
+	n := int64(len(mets))
+	switch {
+	case err == nil:
+		atomic.AddInt64(&c.sent, n)
+		return
+	case isRejected(err):
+		rejected := int64(rejectedItems(err))
+		if rejected > n {
+			rejected = n
+		}
+		atomic.AddInt64(&c.rejected, rejected)
+		atomic.AddInt64(&c.sent, n-rejected)
+	default:
+		atomic.AddInt64(&c.failed, n)
+	}
+
+	if onError != nil {
+		onError(mets, err)
+		return
+	}
+	if isRejected(err) {
+		log.Printf("aura: %v", err)
+	}
+}
+
+func (c *batchCounter) stats() BatchStats {
+	return BatchStats{
+		Sent:     atomic.LoadInt64(&c.sent),
+		Failed:   atomic.LoadInt64(&c.failed),
+		Rejected: atomic.LoadInt64(&c.rejected),
+	}
+}
@@ -1,6 +1,7 @@
 package reporter
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -21,6 +22,8 @@ type StreamReporter struct {
 	Batch          int
 	Ticker         <-chan time.Time
 	MaxConcurrency int
+
+	batcher batcher
 }
 
 func (r *StreamReporter) report(mets []aura.Metric) error {
@@ -33,26 +36,10 @@ func (r *StreamReporter) report(mets []aura.Metric) error {
 }
 
 func (r *StreamReporter) Report(ch chan aura.Metric) {
-	for i := 0; i < r.MaxConcurrency; i++ {
-		go func() {
-			ms := make([]aura.Metric, 0)
-			for {
-				select {
-				case metric := <-ch:
-					if len(ms) >= r.Batch {
-						if err := r.report(ms); err != nil {
-							// what should I do here?
-						}
-						ms = make([]aura.Metric, 0)
-					}
-					ms = append(ms, metric)
-				case <-r.Ticker:
-					if err := r.report(ms); err != nil {
-
-					}
-					ms = make([]aura.Metric, 0)
-				}
-			}
-		}()
-	}
+	r.batcher.run(ch, r.Batch, r.MaxConcurrency, r.Ticker, r.report)
+}
+
+// Flush implements aura.Flusher.
+func (r *StreamReporter) Flush(ctx context.Context) error {
+	return r.batcher.wait(ctx)
 }
@@ -0,0 +1,378 @@
+package reporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+)
+
+const (
+	segmentSuffix = ".seg"
+	ackFilename   = "ack"
+)
+
+// DiskBufferOpts specifies the size of segment files, the size cap of the whole buffer
+// and how often the buffered batches are replayed.
+type DiskBufferOpts struct {
+	SegmentSize    int64
+	MaxSize        int64
+	ReplayInterval time.Duration
+}
+
+// DefaultDiskBufferOpts holds the DiskBufferOpts by default case.
+var DefaultDiskBufferOpts = &DiskBufferOpts{
+	SegmentSize:    4 << 20,
+	MaxSize:        256 << 20,
+	ReplayInterval: 5 * time.Second,
+}
+
+type segment struct {
+	seq  uint64
+	size int64
+}
+
+// DiskBuffer is a write-ahead buffer on the local disk for the batches failed to report.
+// Batches are appended to the segment files, replayed in order once the backend is reachable
+// again and trimmed when acknowledged. The oldest segments will be dropped if the buffer exceeds
+// the MaxSize.
+type DiskBuffer struct {
+	dir  string
+	opts *DiskBufferOpts
+
+	mtx      sync.Mutex
+	segments []*segment
+	head     *os.File
+	size     int64
+	dropped  int64
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+// NewDiskBuffer opens the buffer in the given directory, the segments left by the last run will be replayed.
+func NewDiskBuffer(dir string, opts *DiskBufferOpts) (*DiskBuffer, error) {
+	if opts == nil {
+		opts = DefaultDiskBufferOpts
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	b := &DiskBuffer{
+		dir:     dir,
+		opts:    opts,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ackSeq, _ := b.readAck()
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), segmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		// segments before the acknowledged one have been replayed completely.
+		if seq < ackSeq {
+			os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		b.segments = append(b.segments, &segment{seq: seq, size: f.Size()})
+		b.size += f.Size()
+	}
+
+	sort.Slice(b.segments, func(i, j int) bool { return b.segments[i].seq < b.segments[j].seq })
+	return b, nil
+}
+
+func (b *DiskBuffer) segmentPath(seq uint64) string {
+	return filepath.Join(b.dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
+
+func (b *DiskBuffer) readAck() (uint64, int64) {
+	bs, err := ioutil.ReadFile(filepath.Join(b.dir, ackFilename))
+	if err != nil {
+		return 0, 0
+	}
+
+	var seq uint64
+	var offset int64
+	if _, err := fmt.Sscanf(string(bs), "%d %d", &seq, &offset); err != nil {
+		return 0, 0
+	}
+	return seq, offset
+}
+
+func (b *DiskBuffer) writeAck(seq uint64, offset int64) error {
+	return ioutil.WriteFile(filepath.Join(b.dir, ackFilename), []byte(fmt.Sprintf("%d %d", seq, offset)), 0644)
+}
+
+// roll closes the head segment and creates a new one, b.mtx must be held.
+func (b *DiskBuffer) roll() error {
+	if b.head != nil {
+		b.head.Close()
+		b.head = nil
+	}
+
+	var seq uint64 = 1
+	if n := len(b.segments); n > 0 {
+		seq = b.segments[n-1].seq + 1
+	}
+
+	f, err := os.OpenFile(b.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	b.head = f
+	b.segments = append(b.segments, &segment{seq: seq})
+	return nil
+}
+
+// trim drops the oldest segments until the buffer fits in the MaxSize, b.mtx must be held.
+func (b *DiskBuffer) trim() {
+	for b.opts.MaxSize > 0 && b.size > b.opts.MaxSize && len(b.segments) > 1 {
+		oldest := b.segments[0]
+		os.Remove(b.segmentPath(oldest.seq))
+		b.segments = b.segments[1:]
+		b.size -= oldest.size
+		b.dropped++
+	}
+}
+
+// Append writes the batch to the head segment.
+func (b *DiskBuffer) Append(batch []aura.Metric) error {
+	bs, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	bs = append(bs, '\n')
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	head := (*segment)(nil)
+	if n := len(b.segments); n > 0 && b.head != nil {
+		head = b.segments[n-1]
+	}
+	if head == nil || head.size >= b.opts.SegmentSize {
+		if err := b.roll(); err != nil {
+			return err
+		}
+		head = b.segments[len(b.segments)-1]
+	}
+
+	n, err := b.head.Write(bs)
+	head.size += int64(n)
+	b.size += int64(n)
+	b.trim()
+	return err
+}
+
+// Size returns the bytes of the batches buffered.
+func (b *DiskBuffer) Size() int64 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.size
+}
+
+// Dropped returns the count of segments dropped due to the MaxSize.
+func (b *DiskBuffer) Dropped() int64 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.dropped
+}
+
+// oldest returns the oldest segment, the head segment will be closed if it is the oldest one.
+func (b *DiskBuffer) oldest() *segment {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if len(b.segments) == 0 {
+		return nil
+	}
+	if len(b.segments) == 1 && b.head != nil {
+		b.head.Close()
+		b.head = nil
+	}
+	return b.segments[0]
+}
+
+func (b *DiskBuffer) remove(seg *segment) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	// the segment may have been trimmed already.
+	if len(b.segments) > 0 && b.segments[0] == seg {
+		os.Remove(b.segmentPath(seg.seq))
+		b.segments = b.segments[1:]
+		b.size -= seg.size
+	}
+}
+
+// Replay sends the buffered batches in order and stops at the first failure.
+func (b *DiskBuffer) Replay(send func([]aura.Metric) error) error {
+	for {
+		seg := b.oldest()
+		if seg == nil {
+			return nil
+		}
+
+		if err := b.replaySegment(seg, send); err != nil {
+			return err
+		}
+		b.remove(seg)
+	}
+}
+
+func (b *DiskBuffer) replaySegment(seg *segment, send func([]aura.Metric) error) error {
+	f, err := os.Open(b.segmentPath(seg.seq))
+	if err != nil {
+		// the segment has been trimmed while replaying.
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	var offset int64
+	if ackSeq, ackOffset := b.readAck(); ackSeq == seg.seq {
+		if _, err := f.Seek(ackOffset, io.SeekStart); err != nil {
+			return err
+		}
+		offset = ackOffset
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		batch := make([]aura.Metric, 0)
+		if jerr := json.Unmarshal(line, &batch); jerr == nil && len(batch) > 0 {
+			if err := send(batch); err != nil && !isRejected(err) {
+				return err
+			}
+		}
+
+		offset += int64(len(line))
+		if err := b.writeAck(seg.seq, offset); err != nil {
+			return err
+		}
+	}
+}
+
+// start runs the replaying loop in the background.
+func (b *DiskBuffer) start(send func([]aura.Metric) error) {
+	if b == nil {
+		return
+	}
+
+	b.startOnce.Do(func() {
+		go func() {
+			defer close(b.stopped)
+
+			ticker := time.NewTicker(b.opts.ReplayInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					b.Replay(send)
+				case <-b.stop:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// Close stops the replaying loop and closes the head segment.
+func (b *DiskBuffer) Close() error {
+	if b == nil {
+		return nil
+	}
+
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		started := true
+		b.startOnce.Do(func() { started = false })
+		if started {
+			<-b.stopped
+		}
+
+		b.mtx.Lock()
+		defer b.mtx.Unlock()
+		if b.head != nil {
+			err = b.head.Close()
+			b.head = nil
+		}
+	})
+	return err
+}
+
+// isRejected reports whether the batch has been accepted by the backend with some invalid items,
+// sending it again makes no sense.
+func isRejected(err error) bool {
+	switch err.(type) {
+	case *NightingaleRejectedError, *TransferInvalidError:
+		return true
+	}
+	return false
+}
+
+// unbufferedError is returned by the send function wrapped by withBuffer if the batch failed to send
+// can not be buffered either.
+type unbufferedError struct {
+	err  error
+	berr error
+}
+
+func (e *unbufferedError) Error() string {
+	return fmt.Sprintf("%v, and failed to buffer: %v", e.err, e.berr)
+}
+
+// withBuffer wraps the send function, the batches failed to send are appended to the buffer.
+func withBuffer(buffer *DiskBuffer, send func([]aura.Metric) error) func([]aura.Metric) error {
+	if buffer == nil {
+		return send
+	}
+
+	return func(batch []aura.Metric) error {
+		err := send(batch)
+		if err != nil && !isRejected(err) {
+			if berr := buffer.Append(batch); berr != nil {
+				return &unbufferedError{err: err, berr: berr}
+			}
+		}
+		return err
+	}
+}
@@ -1,11 +1,14 @@
 package reporter
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/rand"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/chenjiandongx/aura"
@@ -30,58 +33,155 @@ func init() {
 }
 
 var DefaultHTTPReporter = &HTTPReporter{
-	client:         defaultHTTPClient,
-	Urls:           []string{},
-	Batch:          200,
-	Ticker:         time.Tick(3 * time.Second),
-	Timeout:        5 * time.Second,
-	RetryCount:     3,
-	MaxConcurrency: 3,
-	DropEndpoint:   false,
+	client:          defaultHTTPClient,
+	Urls:            []string{},
+	Batch:           200,
+	Ticker:          time.Tick(3 * time.Second),
+	Timeout:         5 * time.Second,
+	RetryCount:      3,
+	MaxConcurrency:  3,
+	DropEndpoint:    false,
+	FanOut:          1,
+	BreakerFailures: 3,
+	Backoff:         time.Second,
+	MaxBackoff:      time.Minute,
 }
 
+// HTTPReporterStats holds the count of items sent, failed and dropped by the HTTPReporter.
+type HTTPReporterStats struct {
+	Sent    int64 `json:"sent"`
+	Failed  int64 `json:"failed"`
+	Dropped int64 `json:"dropped"`
+}
+
+// HTTPReporter posts the metrics to the falcon agent `/v1/push` API.
+//
+// A batch is posted to the Urls in random order until FanOut of them succeed.
+// A url will be skipped after BreakerFailures consecutive failures, and it will be tried again after
+// a backoff which starts from Backoff and doubles on every failure up to MaxBackoff.
 type HTTPReporter struct {
-	client         *resty.Client
-	Urls           []string
-	Batch          int
-	Ticker         <-chan time.Time
-	Timeout        time.Duration
-	RetryCount     int
-	MaxConcurrency int
-	DropEndpoint   bool
+	client          *resty.Client
+	Urls            []string
+	Batch           int
+	Ticker          <-chan time.Time
+	Timeout         time.Duration
+	RetryCount      int
+	MaxConcurrency  int
+	DropEndpoint    bool
+	FanOut          int
+	BreakerFailures int
+	Backoff         time.Duration
+	MaxBackoff      time.Duration
+
+	// OnError will be called with the batch failed to report if it is set.
+	OnError func(batch []aura.Metric, err error)
+
+	// Buffer keeps the batches failed to report on the local disk if it is set.
+	Buffer *DiskBuffer
+
+	batcher batcher
+	mtx     sync.Mutex
+	health  map[string]*urlHealth
+	sent    int64
+	failed  int64
+	dropped int64
 }
 
-func (r *HTTPReporter) convert(m aura.Metric) interface{} {
+// urlHealth tracks the consecutive failures of a url for the circuit breaking.
+type urlHealth struct {
+	failures  int
+	openUntil time.Time
+}
+
+// buildTags joins the labels into the falcon tags string, e.g. "k1=v1,k2=v2".
+func buildTags(labels map[string]string, dropEndpoint bool) string {
 	keys := make([]string, 0)
-	for k := range m.Labels {
+	for k := range labels {
+		if dropEndpoint && k == "endpoint" {
+			continue
+		}
 		keys = append(keys, k)
 	}
 
 	sort.Strings(keys)
-	buf := &bytes.Buffer{}
-	for idx, k := range keys {
-		if r.DropEndpoint && k == "endpoint" {
-			continue
-		}
-
-		if idx == len(keys)-1 {
-			buf.WriteString(fmt.Sprintf("%s=%s", k, m.Labels[k]))
-			continue
-		}
-		buf.WriteString(fmt.Sprintf("%s=%s,", k, m.Labels[k]))
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, labels[k]))
 	}
+	return strings.Join(pairs, ",")
+}
+
+func (r *HTTPReporter) convert(m aura.Metric) interface{} {
 	return HTTPReportedMetric{
 		Endpoint:  m.Endpoint,
 		Metric:    m.Metric,
 		Step:      m.Step,
 		Value:     m.Value,
-		Type:      string(m.Type),
-		Tags:      buf.String(),
+		Type:      falconCounterType(m.Type),
+		Tags:      buildTags(m.Labels, r.DropEndpoint),
 		Timestamp: m.Timestamp,
 	}
 }
 
+// available reports whether the circuit of the url is closed or half-open.
+func (r *HTTPReporter) available(url string, now time.Time) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	h, ok := r.health[url]
+	return !ok || !now.Before(h.openUntil)
+}
+
+func (r *HTTPReporter) markHealth(url string, err error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.health == nil {
+		r.health = make(map[string]*urlHealth)
+	}
+	h, ok := r.health[url]
+	if !ok {
+		h = &urlHealth{}
+		r.health[url] = h
+	}
+
+	if err == nil {
+		h.failures = 0
+		h.openUntil = time.Time{}
+		return
+	}
+
+	h.failures++
+	if r.BreakerFailures <= 0 || h.failures < r.BreakerFailures {
+		return
+	}
+
+	backoff := r.Backoff
+	for i := r.BreakerFailures; i < h.failures && (r.MaxBackoff <= 0 || backoff < r.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if r.MaxBackoff > 0 && backoff > r.MaxBackoff {
+		backoff = r.MaxBackoff
+	}
+	h.openUntil = time.Now().Add(backoff)
+}
+
+func (r *HTTPReporter) post(url string, bs []byte) error {
+	resp, err := r.client.R().SetBody(bs).Post(url)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return fmt.Errorf("%s: unexpected status code %d", url, resp.StatusCode())
+	}
+	return nil
+}
+
 func (r *HTTPReporter) report(mets []aura.Metric) error {
+	if len(mets) == 0 {
+		return nil
+	}
+
 	items := make([]interface{}, 0)
 	for _, met := range mets {
 		items = append(items, r.convert(met))
@@ -92,44 +192,90 @@ func (r *HTTPReporter) report(mets []aura.Metric) error {
 		return err
 	}
 
-	ok := false
-	indexes := rand.Perm(len(r.Urls))
-	for _, i := range indexes {
-		_, err := r.client.R().SetBody(bs).Post(r.Urls[i])
+	fanOut := r.FanOut
+	if fanOut < 1 {
+		fanOut = 1
+	}
+
+	succeeded := 0
+	var lastErr error
+	now := time.Now()
+	for _, i := range rand.Perm(len(r.Urls)) {
+		url := r.Urls[i]
+		if !r.available(url, now) {
+			continue
+		}
+
+		err := r.post(url, bs)
+		r.markHealth(url, err)
 		if err != nil {
+			lastErr = err
 			continue
 		}
-		ok = true
+
+		if succeeded++; succeeded >= fanOut {
+			break
+		}
 	}
 
-	if !ok {
-		return fmt.Errorf("failed to report metrics")
+	if succeeded == 0 {
+		if lastErr == nil {
+			return fmt.Errorf("failed to report metrics: no url available")
+		}
+		return fmt.Errorf("failed to report metrics: %v", lastErr)
 	}
 
 	return nil
 }
 
-func (r *HTTPReporter) Report(ch chan aura.Metric) {
-	for i := 0; i < r.MaxConcurrency; i++ {
-		go func() {
-			ms := make([]aura.Metric, 0)
-			for {
-				select {
-				case metric := <-ch:
-					if len(ms) >= r.Batch {
-						if err := r.report(ms); err != nil {
-
-						}
-						ms = make([]aura.Metric, 0)
-					}
-					ms = append(ms, metric)
-				case <-r.Ticker:
-					if err := r.report(ms); err != nil {
-						// what should I do here?
-					}
-					ms = make([]aura.Metric, 0)
-				}
-			}
-		}()
+// send reports the batch and counts the items sent and failed, the batch failed is handed over to the OnError.
+// Both the batches reported and the ones replayed from the Buffer are sent by it.
+func (r *HTTPReporter) send(mets []aura.Metric) error {
+	n := int64(len(mets))
+
+	err := r.report(mets)
+	if err == nil {
+		atomic.AddInt64(&r.sent, n)
+		return nil
+	}
+
+	atomic.AddInt64(&r.failed, n)
+	if r.OnError != nil {
+		r.OnError(mets, err)
+	}
+	return err
+}
+
+// sendOrBuffer sends the batch, the batch failed is appended to the Buffer or counted as dropped.
+func (r *HTTPReporter) sendOrBuffer(mets []aura.Metric) error {
+	err := withBuffer(r.Buffer, r.send)(mets)
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(*unbufferedError); ok || r.Buffer == nil || isRejected(err) {
+		atomic.AddInt64(&r.dropped, int64(len(mets)))
 	}
+	return err
+}
+
+// Stats returns the count of items sent, failed and dropped.
+func (r *HTTPReporter) Stats() HTTPReporterStats {
+	return HTTPReporterStats{
+		Sent:    atomic.LoadInt64(&r.sent),
+		Failed:  atomic.LoadInt64(&r.failed),
+		Dropped: atomic.LoadInt64(&r.dropped),
+	}
+}
+
+func (r *HTTPReporter) Report(ch chan aura.Metric) {
+	r.Buffer.start(r.send)
+	r.batcher.run(ch, r.Batch, r.MaxConcurrency, r.Ticker, r.sendOrBuffer)
+}
+
+// Flush implements aura.Flusher.
+func (r *HTTPReporter) Flush(ctx context.Context) error {
+	err := r.batcher.wait(ctx)
+	r.Buffer.Close()
+	return err
 }
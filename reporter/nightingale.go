@@ -0,0 +1,216 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+	"github.com/go-resty/resty/v2"
+)
+
+// NightingaleReportedMetric is the item of the nightingale push API.
+type NightingaleReportedMetric struct {
+	Nid       string            `json:"nid,omitempty"`
+	Endpoint  string            `json:"endpoint,omitempty"`
+	Metric    string            `json:"metric"`
+	Step      int64             `json:"step"`
+	Value     interface{}       `json:"value"`
+	Type      string            `json:"counterType"`
+	TagsMap   map[string]string `json:"tagsMap"`
+	Extra     string            `json:"extra,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// NightingaleResponse is the response body of the nightingale push API.
+type NightingaleResponse struct {
+	Dat interface{} `json:"dat"`
+	Err string      `json:"err"`
+}
+
+// NightingaleRejectedError holds the per-item rejection messages returned by the server.
+type NightingaleRejectedError struct {
+	Url      string
+	Messages []string
+}
+
+func (e *NightingaleRejectedError) Error() string {
+	return fmt.Sprintf("nightingale(%s): %d metrics are rejected: %s", e.Url, len(e.Messages), strings.Join(e.Messages, "; "))
+}
+
+const (
+	// nidLabel is the label whose value will be used as the node id of a metric.
+	nidLabel = "nid"
+	// extraLabel is the label whose value will be used as the extra field of a metric.
+	extraLabel = "extra"
+)
+
+var DefaultNightingaleReporter = &NightingaleReporter{
+	client:         defaultHTTPClient,
+	Urls:           []string{},
+	Nid:            "",
+	Batch:          200,
+	Ticker:         time.Tick(3 * time.Second),
+	MaxConcurrency: 3,
+}
+
+// NightingaleReporter reports metrics to the nightingale collector push API,
+// e.g. http://127.0.0.1:8002/api/transfer/push.
+//
+// A metric is reported in the node mode if Nid is set or the metric has a `nid` label,
+// otherwise it will be reported in the endpoint mode. The extra field is taken from Extra or the
+// `extra` label of a metric in the same way.
+type NightingaleReporter struct {
+	client         *resty.Client
+	Urls           []string
+	Nid            string
+	Extra          string
+	Batch          int
+	Ticker         <-chan time.Time
+	MaxConcurrency int
+
+	// OnError will be called with the batch failed to report or rejected if it is set,
+	// the rejection messages are logged otherwise.
+	OnError func(batch []aura.Metric, err error)
+
+	// Buffer keeps the batches failed to report on the local disk if it is set.
+	Buffer *DiskBuffer
+
+	batcher batcher
+	counter batchCounter
+}
+
+// normalizeTimestamp converts the timestamp in milliseconds to seconds which nightingale expects.
+func normalizeTimestamp(ts int64) int64 {
+	switch {
+	case ts <= 0:
+		return time.Now().Unix()
+	case ts > 1e11:
+		return ts / 1000
+	}
+	return ts
+}
+
+func (r *NightingaleReporter) convert(m aura.Metric) *NightingaleReportedMetric {
+	nid := r.Nid
+	if v, ok := m.Labels[nidLabel]; ok && v != "" {
+		nid = v
+	}
+	extra := r.Extra
+	if v, ok := m.Labels[extraLabel]; ok && v != "" {
+		extra = v
+	}
+
+	tags := make(map[string]string)
+	for k, v := range m.Labels {
+		if k == nidLabel || k == extraLabel || k == "endpoint" {
+			continue
+		}
+		tags[k] = v
+	}
+
+	item := &NightingaleReportedMetric{
+		Metric:    m.Metric,
+		Step:      int64(m.Step),
+		Value:     m.Value,
+		Type:      falconCounterType(m.Type),
+		TagsMap:   tags,
+		Extra:     extra,
+		Timestamp: normalizeTimestamp(m.Timestamp),
+	}
+
+	if nid != "" {
+		item.Nid = nid
+	} else {
+		item.Endpoint = m.Endpoint
+	}
+	return item
+}
+
+func (r *NightingaleReporter) report(mets []aura.Metric) error {
+	if len(mets) == 0 {
+		return nil
+	}
+
+	items := make([]*NightingaleReportedMetric, 0, len(mets))
+	for _, met := range mets {
+		items = append(items, r.convert(met))
+	}
+
+	bs, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	client := r.client
+	if client == nil {
+		client = defaultHTTPClient
+	}
+
+	var lastErr error
+	for _, i := range rand.Perm(len(r.Urls)) {
+		resp, err := client.R().SetBody(bs).SetHeader("Content-Type", "application/json").Post(r.Urls[i])
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.IsError() {
+			lastErr = fmt.Errorf("nightingale(%s): unexpected status code %d", r.Urls[i], resp.StatusCode())
+			continue
+		}
+
+		ret := &NightingaleResponse{}
+		if err := json.Unmarshal(resp.Body(), ret); err != nil {
+			return fmt.Errorf("nightingale(%s): invalid response: %v", r.Urls[i], err)
+		}
+
+		// the batch has been accepted, only the invalid items are rejected.
+		if ret.Err != "" {
+			return &NightingaleRejectedError{Url: r.Urls[i], Messages: splitRejectedMessages(ret.Err)}
+		}
+		return nil
+	}
+
+	if lastErr == nil {
+		return fmt.Errorf("no nightingale url available")
+	}
+	return fmt.Errorf("failed to report metrics: %v", lastErr)
+}
+
+func splitRejectedMessages(s string) []string {
+	messages := make([]string, 0)
+	for _, msg := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == '\n' }) {
+		if msg = strings.TrimSpace(msg); msg != "" {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// send reports the batch and counts the result, both the batches reported and the ones replayed
+// from the Buffer are sent by it.
+func (r *NightingaleReporter) send(mets []aura.Metric) error {
+	err := r.report(mets)
+	r.counter.count(mets, err, r.OnError)
+	return err
+}
+
+// Stats returns the count of items sent, failed and rejected.
+func (r *NightingaleReporter) Stats() BatchStats {
+	return r.counter.stats()
+}
+
+func (r *NightingaleReporter) Report(ch chan aura.Metric) {
+	r.Buffer.start(r.send)
+	r.batcher.run(ch, r.Batch, r.MaxConcurrency, r.Ticker, withBuffer(r.Buffer, r.send))
+}
+
+// Flush implements aura.Flusher.
+func (r *NightingaleReporter) Flush(ctx context.Context) error {
+	err := r.batcher.wait(ctx)
+	r.Buffer.Close()
+	return err
+}
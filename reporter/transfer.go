@@ -0,0 +1,253 @@
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"time"
+
+	"github.com/chenjiandongx/aura"
+)
+
+// TransferReportedMetric is the item of the `Transfer.Update` RPC call.
+type TransferReportedMetric struct {
+	Endpoint  string      `json:"endpoint"`
+	Metric    string      `json:"metric"`
+	Value     interface{} `json:"value"`
+	Step      int64       `json:"step"`
+	Type      string      `json:"counterType"`
+	Tags      string      `json:"tags"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// TransferResponse is the reply of the `Transfer.Update` RPC call.
+type TransferResponse struct {
+	Message string
+	Total   int
+	Invalid int
+	Latency int64
+}
+
+// TransferInvalidError indicates that the batch has been accepted but some items are invalid.
+type TransferInvalidError struct {
+	Addr     string
+	Response *TransferResponse
+}
+
+func (e *TransferInvalidError) Error() string {
+	return fmt.Sprintf("transfer(%s): %d of %d metrics are invalid: %s",
+		e.Addr, e.Response.Invalid, e.Response.Total, e.Response.Message,
+	)
+}
+
+var DefaultTransferReporter = &TransferReporter{
+	Addrs:          []string{},
+	Batch:          200,
+	Ticker:         time.Tick(3 * time.Second),
+	DialTimeout:    3 * time.Second,
+	CallTimeout:    5 * time.Second,
+	MaxIdleConns:   4,
+	MaxConcurrency: 3,
+	DropEndpoint:   false,
+}
+
+// TransferReporter reports metrics to the falcon transfer directly over its JSON-RPC protocol.
+// The addresses are tried in random order until one of them accepts the batch.
+type TransferReporter struct {
+	Addrs          []string
+	Batch          int
+	Ticker         <-chan time.Time
+	DialTimeout    time.Duration
+	CallTimeout    time.Duration
+	MaxIdleConns   int
+	MaxConcurrency int
+	DropEndpoint   bool
+
+	// OnError will be called with the batch failed to report or having invalid items if it is set,
+	// the invalid items are logged otherwise.
+	OnError func(batch []aura.Metric, err error)
+
+	// Buffer keeps the batches failed to report on the local disk if it is set.
+	Buffer *DiskBuffer
+
+	batcher batcher
+	counter batchCounter
+	once    sync.Once
+	pools   []*transferConnPool
+}
+
+// falconCounterType maps the aura.ValueType to the counterType which falcon accepts.
+func falconCounterType(t aura.ValueType) string {
+	if t == aura.CounterValue {
+		return "COUNTER"
+	}
+	return "GAUGE"
+}
+
+type transferConnPool struct {
+	addr        string
+	dialTimeout time.Duration
+	maxIdle     int
+
+	mtx  sync.Mutex
+	idle []*rpc.Client
+}
+
+// get returns an idle client if there is one, pooled tells whether the client is an idle one.
+func (p *transferConnPool) get() (client *rpc.Client, pooled bool, err error) {
+	p.mtx.Lock()
+	if n := len(p.idle); n > 0 {
+		client := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mtx.Unlock()
+		return client, true, nil
+	}
+	p.mtx.Unlock()
+
+	client, err = p.dial()
+	return client, false, err
+}
+
+func (p *transferConnPool) dial() (*rpc.Client, error) {
+	conn, err := net.DialTimeout("tcp", p.addr, p.dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc.NewClient(conn), nil
+}
+
+func (p *transferConnPool) put(client *rpc.Client, broken bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if broken || len(p.idle) >= p.maxIdle {
+		client.Close()
+		return
+	}
+	p.idle = append(p.idle, client)
+}
+
+// call calls `Transfer.Update` with the items. An idle connection may have been closed by the transfer,
+// so the address is redialed once if the call on it breaks.
+func (p *transferConnPool) call(items []*TransferReportedMetric, timeout time.Duration) (*TransferResponse, error) {
+	client, pooled, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, broken, err := p.invoke(client, items, timeout)
+	if broken && pooled {
+		if client, err = p.dial(); err != nil {
+			return nil, err
+		}
+		reply, _, err = p.invoke(client, items, timeout)
+	}
+	return reply, err
+}
+
+// invoke calls `Transfer.Update` on the client and puts it back, broken tells whether the connection
+// has been broken before the call completes, a timeout is not regarded as broken.
+func (p *transferConnPool) invoke(client *rpc.Client, items []*TransferReportedMetric, timeout time.Duration) (*TransferResponse, bool, error) {
+	reply := &TransferResponse{}
+	call := client.Go("Transfer.Update", items, reply, make(chan *rpc.Call, 1))
+
+	select {
+	case <-call.Done:
+		// errors returned by the server don't break the connection.
+		_, isServerErr := call.Error.(rpc.ServerError)
+		broken := call.Error != nil && !isServerErr
+		p.put(client, broken)
+		if call.Error != nil {
+			return nil, broken, call.Error
+		}
+		return reply, false, nil
+	case <-time.After(timeout):
+		p.put(client, true)
+		return nil, false, fmt.Errorf("transfer(%s): call timeout after %v", p.addr, timeout)
+	}
+}
+
+func (r *TransferReporter) init() {
+	r.once.Do(func() {
+		for _, addr := range r.Addrs {
+			r.pools = append(r.pools, &transferConnPool{
+				addr:        addr,
+				dialTimeout: r.DialTimeout,
+				maxIdle:     r.MaxIdleConns,
+			})
+		}
+	})
+}
+
+func (r *TransferReporter) convert(m aura.Metric) *TransferReportedMetric {
+	return &TransferReportedMetric{
+		Endpoint:  m.Endpoint,
+		Metric:    m.Metric,
+		Value:     m.Value,
+		Step:      int64(m.Step),
+		Type:      falconCounterType(m.Type),
+		Tags:      buildTags(m.Labels, r.DropEndpoint),
+		Timestamp: m.Timestamp,
+	}
+}
+
+func (r *TransferReporter) report(mets []aura.Metric) error {
+	if len(mets) == 0 {
+		return nil
+	}
+
+	items := make([]*TransferReportedMetric, 0, len(mets))
+	for _, met := range mets {
+		items = append(items, r.convert(met))
+	}
+
+	var lastErr error
+	for _, i := range rand.Perm(len(r.pools)) {
+		reply, err := r.pools[i].call(items, r.CallTimeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		// the batch has been accepted, the invalid items will not be better on other transfers.
+		if reply.Invalid > 0 {
+			return &TransferInvalidError{Addr: r.pools[i].addr, Response: reply}
+		}
+		return nil
+	}
+
+	if lastErr == nil {
+		return fmt.Errorf("no transfer address available")
+	}
+	return fmt.Errorf("failed to report metrics: %v", lastErr)
+}
+
+// send reports the batch and counts the result, both the batches reported and the ones replayed
+// from the Buffer are sent by it.
+func (r *TransferReporter) send(mets []aura.Metric) error {
+	err := r.report(mets)
+	r.counter.count(mets, err, r.OnError)
+	return err
+}
+
+// Stats returns the count of items sent, failed and invalid.
+func (r *TransferReporter) Stats() BatchStats {
+	return r.counter.stats()
+}
+
+func (r *TransferReporter) Report(ch chan aura.Metric) {
+	r.init()
+	r.Buffer.start(r.send)
+	r.batcher.run(ch, r.Batch, r.MaxConcurrency, r.Ticker, withBuffer(r.Buffer, r.send))
+}
+
+// Flush implements aura.Flusher.
+func (r *TransferReporter) Flush(ctx context.Context) error {
+	err := r.batcher.wait(ctx)
+	r.Buffer.Close()
+	return err
+}
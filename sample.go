@@ -0,0 +1,332 @@
+package aura
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// SampleType specifies how a Histogram or a Timer samples the observations.
+type SampleType string
+
+const (
+	// SampleExpDecay keeps a reservoir of Size observations biased toward the last 5 minutes by Alpha.
+	SampleExpDecay SampleType = "expDecay"
+
+	// SampleUniform keeps a reservoir of Size observations chosen uniformly from all the observations.
+	SampleUniform SampleType = "uniform"
+
+	// SampleSlidingWindow keeps the observations in the last Window, at most Size of them.
+	SampleSlidingWindow SampleType = "slidingWindow"
+
+	// SampleResetOnCollect keeps a uniform reservoir of Size observations which is cleared on every collecting,
+	// so the reported values only reflect the observations of the last interval.
+	SampleResetOnCollect SampleType = "resetOnCollect"
+)
+
+type SampleOpts struct {
+	Type   SampleType
+	Size   int
+	Alpha  float64
+	Window time.Duration
+}
+
+var (
+	DefaultSampleOpts = &SampleOpts{
+		Type:   SampleExpDecay,
+		Size:   1028,
+		Alpha:  0.015,
+		Window: time.Minute,
+	}
+)
+
+// distribution is the common part of metrics.Sample and metrics.Timer which describes the observations.
+type distribution interface {
+	Count() int64
+	Max() int64
+	Mean() float64
+	Min() int64
+	Percentile(float64) float64
+	StdDev() float64
+	Sum() int64
+	Variance() float64
+}
+
+// newSample returns the reservoir owned by a histogram or a timer, it is never shared between them.
+// The zero fields of the opts fall back to the DefaultSampleOpts.
+func newSample(opts *SampleOpts) metrics.Sample {
+	if opts == nil {
+		opts = DefaultSampleOpts
+	}
+
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultSampleOpts.Size
+	}
+
+	switch opts.Type {
+	case SampleUniform:
+		return metrics.NewUniformSample(size)
+	case SampleSlidingWindow:
+		window := opts.Window
+		if window <= 0 {
+			window = DefaultSampleOpts.Window
+		}
+		return newSlidingWindowSample(size, window)
+	case SampleResetOnCollect:
+		return newResetSample(size)
+	}
+
+	alpha := opts.Alpha
+	if alpha <= 0 {
+		alpha = DefaultSampleOpts.Alpha
+	}
+	return metrics.NewExpDecaySample(size, alpha)
+}
+
+// slidingWindowSample keeps the observations in the last window, the oldest ones are dropped
+// once there are more than size observations in the window. The observations are kept in a ring
+// buffer, so both adding and expiring them are O(1) amortized.
+type slidingWindowSample struct {
+	mtx    sync.Mutex
+	size   int
+	window time.Duration
+	count  int64
+	vals   []int64
+	times  []int64
+	head   int
+	n      int
+}
+
+func newSlidingWindowSample(size int, window time.Duration) *slidingWindowSample {
+	return &slidingWindowSample{size: size, window: window}
+}
+
+// trim drops the observations out of the window, s.mtx must be held.
+func (s *slidingWindowSample) trim(now int64) {
+	deadline := now - int64(s.window)
+	for s.n > 0 && s.times[s.head] < deadline {
+		s.head = (s.head + 1) % len(s.vals)
+		s.n--
+	}
+}
+
+// Clear clears all the observations.
+func (s *slidingWindowSample) Clear() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.count = 0
+	s.head = 0
+	s.n = 0
+}
+
+// Count returns the number of observations recorded, which may exceed the size.
+func (s *slidingWindowSample) Count() int64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.count
+}
+
+// Max returns the maximum value in the window.
+func (s *slidingWindowSample) Max() int64 {
+	return metrics.SampleMax(s.Values())
+}
+
+// Mean returns the mean of the values in the window.
+func (s *slidingWindowSample) Mean() float64 {
+	return metrics.SampleMean(s.Values())
+}
+
+// Min returns the minimum value in the window.
+func (s *slidingWindowSample) Min() int64 {
+	return metrics.SampleMin(s.Values())
+}
+
+// Percentile returns an arbitrary percentile of the values in the window.
+func (s *slidingWindowSample) Percentile(p float64) float64 {
+	return metrics.SamplePercentile(s.Values(), p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of the values in the window.
+func (s *slidingWindowSample) Percentiles(ps []float64) []float64 {
+	return metrics.SamplePercentiles(s.Values(), ps)
+}
+
+// Size returns the number of values in the window.
+func (s *slidingWindowSample) Size() int {
+	return len(s.Values())
+}
+
+// Snapshot returns a read-only copy of the sample.
+func (s *slidingWindowSample) Snapshot() metrics.Sample {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return metrics.NewSampleSnapshot(s.count, s.values())
+}
+
+// StdDev returns the standard deviation of the values in the window.
+func (s *slidingWindowSample) StdDev() float64 {
+	return metrics.SampleStdDev(s.Values())
+}
+
+// Sum returns the sum of the values in the window.
+func (s *slidingWindowSample) Sum() int64 {
+	return metrics.SampleSum(s.Values())
+}
+
+// Update records a new observation.
+func (s *slidingWindowSample) Update(v int64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// the buffers are allocated on the first observation, since many children of a Vec are never observed.
+	if s.vals == nil {
+		s.vals = make([]int64, s.size)
+		s.times = make([]int64, s.size)
+	}
+
+	now := time.Now().UnixNano()
+	s.count++
+	s.trim(now)
+	if s.n == s.size {
+		s.head = (s.head + 1) % s.size
+		s.n--
+	}
+
+	idx := (s.head + s.n) % s.size
+	s.vals[idx] = v
+	s.times[idx] = now
+	s.n++
+}
+
+// Values returns a copy of the values in the window.
+func (s *slidingWindowSample) Values() []int64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.values()
+}
+
+// values returns a copy of the values in the window, s.mtx must be held.
+func (s *slidingWindowSample) values() []int64 {
+	s.trim(time.Now().UnixNano())
+	values := make([]int64, s.n)
+	for i := range values {
+		values[i] = s.vals[(s.head+i)%len(s.vals)]
+	}
+	return values
+}
+
+// Variance returns the variance of the values in the window.
+func (s *slidingWindowSample) Variance() float64 {
+	return metrics.SampleVariance(s.Values())
+}
+
+// resetSample is a uniform sample which is swapped for an empty one on every collecting.
+type resetSample struct {
+	mtx  sync.RWMutex
+	size int
+	self metrics.Sample
+}
+
+func newResetSample(size int) *resetSample {
+	return &resetSample{size: size, self: metrics.NewUniformSample(size)}
+}
+
+func (s *resetSample) current() metrics.Sample {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.self
+}
+
+// reset returns the snapshot of the observations since the last reset and clears the sample.
+func (s *resetSample) reset() metrics.Sample {
+	s.mtx.Lock()
+	old := s.self
+	s.self = metrics.NewUniformSample(s.size)
+	s.mtx.Unlock()
+
+	return old.Snapshot()
+}
+
+// Clear clears all the observations.
+func (s *resetSample) Clear() {
+	s.current().Clear()
+}
+
+// Count returns the number of observations since the last reset.
+func (s *resetSample) Count() int64 {
+	return s.current().Count()
+}
+
+// Max returns the maximum value since the last reset.
+func (s *resetSample) Max() int64 {
+	return s.current().Max()
+}
+
+// Mean returns the mean of the values since the last reset.
+func (s *resetSample) Mean() float64 {
+	return s.current().Mean()
+}
+
+// Min returns the minimum value since the last reset.
+func (s *resetSample) Min() int64 {
+	return s.current().Min()
+}
+
+// Percentile returns an arbitrary percentile of the values since the last reset.
+func (s *resetSample) Percentile(p float64) float64 {
+	return s.current().Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of the values since the last reset.
+func (s *resetSample) Percentiles(ps []float64) []float64 {
+	return s.current().Percentiles(ps)
+}
+
+// Size returns the size of the sample.
+func (s *resetSample) Size() int {
+	return s.current().Size()
+}
+
+// Snapshot returns a read-only copy of the sample without resetting it.
+func (s *resetSample) Snapshot() metrics.Sample {
+	return s.current().Snapshot()
+}
+
+// StdDev returns the standard deviation of the values since the last reset.
+func (s *resetSample) StdDev() float64 {
+	return s.current().StdDev()
+}
+
+// Sum returns the sum of the values since the last reset.
+func (s *resetSample) Sum() int64 {
+	return s.current().Sum()
+}
+
+// Update records a new observation, the read lock is held so that it is never lost by a concurrent reset.
+func (s *resetSample) Update(v int64) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	s.self.Update(v)
+}
+
+// Values returns a copy of the values since the last reset.
+func (s *resetSample) Values() []int64 {
+	return s.current().Values()
+}
+
+// Variance returns the variance of the values since the last reset.
+func (s *resetSample) Variance() float64 {
+	return s.current().Variance()
+}
+
+// snapshotSample returns the snapshot of the sample to be reported, the sample is reset
+// at the same time if it resets on collecting.
+func snapshotSample(s metrics.Sample) metrics.Sample {
+	if r, ok := s.(*resetSample); ok {
+		return r.reset()
+	}
+	return s.Snapshot()
+}
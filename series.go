@@ -0,0 +1,65 @@
+package aura
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// staleSteps is the number of steps after which a series without any update is considered stale.
+const staleSteps = 5
+
+// seriesSnapshot keeps the most recently collected value of every series.
+type seriesSnapshot struct {
+	mtx    sync.RWMutex
+	series map[string]Metric
+}
+
+func newSeriesSnapshot() *seriesSnapshot {
+	return &seriesSnapshot{series: map[string]Metric{}}
+}
+
+// seriesKey identifies a series by its endpoint, metric name and labels.
+func seriesKey(m Metric) string {
+	keys := make([]string, 0, len(m.Labels))
+	for k := range m.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, m.Labels[k])
+	}
+	return m.Endpoint + "/" + makeLabelPairs(m.Metric, keys, values)
+}
+
+func (s *seriesSnapshot) update(key string, m Metric) {
+	s.mtx.Lock()
+	s.series[key] = m
+	s.mtx.Unlock()
+}
+
+// metrics returns all the series sorted by the metric name and prunes the stale ones.
+func (s *seriesSnapshot) metrics() []Metric {
+	now := time.Now().Unix()
+
+	s.mtx.Lock()
+	ret := make([]Metric, 0, len(s.series))
+	for k, m := range s.series {
+		if m.Step > 0 && now-m.Timestamp > int64(staleSteps*m.Step) {
+			delete(s.series, k)
+			continue
+		}
+		ret = append(ret, m)
+	}
+	s.mtx.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Metric != ret[j].Metric {
+			return ret[i].Metric < ret[j].Metric
+		}
+		return seriesKey(ret[i]) < seriesKey(ret[j])
+	})
+	return ret
+}
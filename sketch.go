@@ -0,0 +1,493 @@
+package aura
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// DefaultSketchAccuracy is the relative accuracy of the sketches by default.
+const DefaultSketchAccuracy = 0.01
+
+// sketchEncodingVersion is the first byte of the binary form of a Sketch.
+const sketchEncodingVersion byte = 1
+
+// Sketch is a mergeable quantile sketch in the spirit of DDSketch. The observations are counted in
+// logarithmic buckets, so every quantile is estimated with the relative accuracy given, and sketches
+// with the same accuracy can be merged without losing any accuracy, e.g. across the label values or
+// the processes. It is safe for concurrent use, and the zero value is an empty Sketch with the
+// DefaultSketchAccuracy.
+type Sketch struct {
+	mtx sync.Mutex
+
+	accuracy float64
+	gamma    float64
+	logGamma float64
+
+	positive map[int]uint64
+	negative map[int]uint64
+	zero     uint64
+
+	count uint64
+	sum   float64
+	sumSq float64
+	min   float64
+	max   float64
+}
+
+// NewSketch returns an empty Sketch, the relativeAccuracy should be in (0, 1).
+func NewSketch(relativeAccuracy float64) (*Sketch, error) {
+	if relativeAccuracy <= 0 || relativeAccuracy >= 1 {
+		return nil, fmt.Errorf("relative accuracy %v should be in (0, 1)", relativeAccuracy)
+	}
+
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &Sketch{
+		accuracy: relativeAccuracy,
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		positive: map[int]uint64{},
+		negative: map[int]uint64{},
+		min:      math.Inf(1),
+		max:      math.Inf(-1),
+	}, nil
+}
+
+// MustNewSketch is like NewSketch but panics if an error occurs.
+func MustNewSketch(relativeAccuracy float64) *Sketch {
+	s, err := NewSketch(relativeAccuracy)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// init sets up the zero value of the sketch, s.mtx must be held.
+func (s *Sketch) init() {
+	if s.gamma == 0 {
+		s.accuracy = DefaultSketchAccuracy
+		s.gamma = (1 + s.accuracy) / (1 - s.accuracy)
+		s.logGamma = math.Log(s.gamma)
+	}
+	if s.positive == nil {
+		s.positive = map[int]uint64{}
+	}
+	if s.negative == nil {
+		s.negative = map[int]uint64{}
+	}
+}
+
+// index returns the bucket of the absolute value v.
+func (s *Sketch) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+// value returns the value of the bucket which is within the relative accuracy of all the values in it.
+func (s *Sketch) value(idx int) float64 {
+	return 2 * math.Pow(s.gamma, float64(idx)) / (s.gamma + 1)
+}
+
+// Add records the observation, NaN and ±Inf are ignored since they belong to no bucket.
+func (s *Sketch) Add(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.init()
+
+	switch {
+	case v > 0:
+		s.positive[s.index(v)]++
+	case v < 0:
+		s.negative[s.index(-v)]++
+	default:
+		s.zero++
+	}
+
+	if s.count == 0 || v < s.min {
+		s.min = v
+	}
+	if s.count == 0 || v > s.max {
+		s.max = v
+	}
+	s.count++
+	s.sum += v
+	s.sumSq += v * v
+}
+
+// Merge adds all the observations of o into s, both should have the same relative accuracy.
+func (s *Sketch) Merge(o *Sketch) error {
+	if s == o {
+		return errors.New("cannot merge a sketch into itself")
+	}
+
+	o.mtx.Lock()
+	o.init()
+	other := o.copy()
+	o.mtx.Unlock()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.init()
+	if s.accuracy != other.accuracy {
+		return fmt.Errorf("cannot merge the sketch with relative accuracy %v into %v", other.accuracy, s.accuracy)
+	}
+
+	for idx, n := range other.positive {
+		s.positive[idx] += n
+	}
+	for idx, n := range other.negative {
+		s.negative[idx] += n
+	}
+	if other.count == 0 {
+		return nil
+	}
+	if s.count == 0 {
+		s.min, s.max = other.min, other.max
+	} else {
+		s.min = math.Min(s.min, other.min)
+		s.max = math.Max(s.max, other.max)
+	}
+	s.zero += other.zero
+	s.count += other.count
+	s.sum += other.sum
+	s.sumSq += other.sumSq
+	return nil
+}
+
+// copy returns a copy of the sketch, s.mtx must be held.
+func (s *Sketch) copy() *Sketch {
+	c := &Sketch{
+		accuracy: s.accuracy,
+		gamma:    s.gamma,
+		logGamma: s.logGamma,
+		positive: make(map[int]uint64, len(s.positive)),
+		negative: make(map[int]uint64, len(s.negative)),
+		zero:     s.zero,
+		count:    s.count,
+		sum:      s.sum,
+		sumSq:    s.sumSq,
+		min:      s.min,
+		max:      s.max,
+	}
+	for idx, n := range s.positive {
+		c.positive[idx] = n
+	}
+	for idx, n := range s.negative {
+		c.negative[idx] = n
+	}
+	return c
+}
+
+// take returns a copy of the sketch and clears it.
+func (s *Sketch) take() *Sketch {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.init()
+	c := s.copy()
+	s.positive = map[int]uint64{}
+	s.negative = map[int]uint64{}
+	s.zero, s.count = 0, 0
+	s.sum, s.sumSq = 0, 0
+	s.min, s.max = math.Inf(1), math.Inf(-1)
+	return c
+}
+
+// Quantile returns the estimated value of the quantile q in [0, 1], zero if the sketch is empty.
+func (s *Sketch) Quantile(q float64) float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.count == 0 || q < 0 || q > 1 {
+		return 0
+	}
+
+	rank := q * float64(s.count-1)
+	var cumulative float64
+
+	negative := sortedIndexes(s.negative)
+	for i := len(negative) - 1; i >= 0; i-- {
+		cumulative += float64(s.negative[negative[i]])
+		if cumulative > rank {
+			return s.clamp(-s.value(negative[i]))
+		}
+	}
+
+	cumulative += float64(s.zero)
+	if cumulative > rank {
+		return 0
+	}
+
+	for _, idx := range sortedIndexes(s.positive) {
+		cumulative += float64(s.positive[idx])
+		if cumulative > rank {
+			return s.clamp(s.value(idx))
+		}
+	}
+	return s.max
+}
+
+// clamp keeps the estimated value within the observed range, s.mtx must be held.
+func (s *Sketch) clamp(v float64) float64 {
+	return math.Max(s.min, math.Min(s.max, v))
+}
+
+func sortedIndexes(buckets map[int]uint64) []int {
+	indexes := make([]int, 0, len(buckets))
+	for idx := range buckets {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// RelativeAccuracy returns the relative accuracy of the sketch.
+func (s *Sketch) RelativeAccuracy() float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.init()
+	return s.accuracy
+}
+
+// Count returns the number of observations.
+func (s *Sketch) Count() uint64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.count
+}
+
+// Sum returns the sum of the observations.
+func (s *Sketch) Sum() float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.sum
+}
+
+// Min returns the minimum observation, zero if the sketch is empty.
+func (s *Sketch) Min() float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.count == 0 {
+		return 0
+	}
+	return s.min
+}
+
+// Max returns the maximum observation, zero if the sketch is empty.
+func (s *Sketch) Max() float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.count == 0 {
+		return 0
+	}
+	return s.max
+}
+
+// Mean returns the mean of the observations, zero if the sketch is empty.
+func (s *Sketch) Mean() float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / float64(s.count)
+}
+
+// Variance returns the variance of the observations, zero if the sketch is empty.
+func (s *Sketch) Variance() float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.count == 0 {
+		return 0
+	}
+	mean := s.sum / float64(s.count)
+	return math.Max(0, s.sumSq/float64(s.count)-mean*mean)
+}
+
+// StdDev returns the standard deviation of the observations, zero if the sketch is empty.
+func (s *Sketch) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, the result can be restored by UnmarshalBinary
+// and merged with the other sketches, e.g. on the server side.
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.init()
+	buf := make([]byte, 0, 1+8*6+2*binary.MaxVarintLen64*(2+len(s.positive)+len(s.negative)))
+	buf = append(buf, sketchEncodingVersion)
+	for _, f := range []float64{s.accuracy, s.sum, s.sumSq, s.min, s.max} {
+		buf = appendUint64(buf, math.Float64bits(f))
+	}
+	buf = appendUvarint(buf, s.zero)
+	buf = appendBuckets(buf, s.positive)
+	buf = appendBuckets(buf, s.negative)
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, the sketch is replaced by the decoded one.
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	d := &sketchDecoder{data: data}
+	if version := d.readByte(); d.err == nil && version != sketchEncodingVersion {
+		return fmt.Errorf("unknown sketch encoding version %d", version)
+	}
+
+	fs := make([]float64, 5)
+	for i := range fs {
+		fs[i] = math.Float64frombits(d.readUint64())
+	}
+	zero := d.readUvarint()
+	positive := d.readBuckets()
+	negative := d.readBuckets()
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.data) > 0 {
+		return errors.New("sketch: unexpected trailing bytes")
+	}
+
+	decoded, err := NewSketch(fs[0])
+	if err != nil {
+		return fmt.Errorf("sketch: %v", err)
+	}
+	decoded.sum, decoded.sumSq, decoded.min, decoded.max = fs[1], fs[2], fs[3], fs[4]
+	decoded.zero, decoded.positive, decoded.negative = zero, positive, negative
+	decoded.count = zero
+	for _, n := range positive {
+		decoded.count += n
+	}
+	for _, n := range negative {
+		decoded.count += n
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.accuracy, s.gamma, s.logGamma = decoded.accuracy, decoded.gamma, decoded.logGamma
+	s.positive, s.negative, s.zero = decoded.positive, decoded.negative, decoded.zero
+	s.count, s.sum, s.sumSq, s.min, s.max = decoded.count, decoded.sum, decoded.sumSq, decoded.min, decoded.max
+	return nil
+}
+
+// UnmarshalSketch decodes the sketch encoded by Sketch.MarshalBinary.
+func UnmarshalSketch(data []byte) (*Sketch, error) {
+	s := &Sketch{}
+	if err := s.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func appendBuckets(buf []byte, buckets map[int]uint64) []byte {
+	buf = appendUvarint(buf, uint64(len(buckets)))
+	for _, idx := range sortedIndexes(buckets) {
+		var b [binary.MaxVarintLen64]byte
+		buf = append(buf, b[:binary.PutVarint(b[:], int64(idx))]...)
+		buf = appendUvarint(buf, buckets[idx])
+	}
+	return buf
+}
+
+// sketchDecoder reads the binary form of a Sketch, the first error is kept and the following reads are no-ops.
+type sketchDecoder struct {
+	data []byte
+	err  error
+}
+
+var errSketchTruncated = errors.New("sketch: truncated data")
+
+func (d *sketchDecoder) readByte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) < 1 {
+		d.err = errSketchTruncated
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *sketchDecoder) readUint64() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) < 8 {
+		d.err = errSketchTruncated
+		return 0
+	}
+	v := binary.LittleEndian.Uint64(d.data)
+	d.data = d.data[8:]
+	return v
+}
+
+func (d *sketchDecoder) readUvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = errSketchTruncated
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *sketchDecoder) readVarint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.err = errSketchTruncated
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *sketchDecoder) readBuckets() map[int]uint64 {
+	n := d.readUvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.data)) {
+		d.err = errSketchTruncated
+		return nil
+	}
+
+	buckets := make(map[int]uint64, n)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		idx := d.readVarint()
+		buckets[int(idx)] = d.readUvarint()
+	}
+	return buckets
+}
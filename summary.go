@@ -0,0 +1,321 @@
+package aura
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Summary estimates the quantiles of the observations in a sliding time window with a streaming
+// quantile sketch, the rank error of every quantile is bounded by its objective. Unlike the sampled
+// Histogram, no observation is dropped, so the tail quantiles are accurate under high throughput.
+type Summary interface {
+	Collector
+
+	Observe(float64)
+}
+
+type SummaryOpts struct {
+	// Objectives maps the quantiles to their absolute rank errors, e.g. {0.99: 0.001} reports the value
+	// whose rank is between 0.989 and 0.991. Every quantile and error should be in (0, 1).
+	Objectives map[float64]float64
+
+	// MaxAge is how long the observations are kept for the quantiles, they are split into AgeBuckets
+	// streams rotated every MaxAge/AgeBuckets, so the window slides smoothly. The quantiles cover the
+	// observations of the last MaxAge-MaxAge/AgeBuckets to MaxAge.
+	MaxAge     time.Duration
+	AgeBuckets int
+
+	// BufCap is the number of observations buffered before being inserted into the streams in a batch.
+	BufCap int
+
+	// VecOpts only works for the SummaryVec.
+	VecOpts
+}
+
+var (
+	DefaultObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
+
+	DefaultSummaryOpts = &SummaryOpts{
+		Objectives: DefaultObjectives,
+		MaxAge:     10 * time.Minute,
+		AgeBuckets: 5,
+		BufCap:     500,
+	}
+)
+
+// checkSummaryOpts returns the opts with the zero fields set to the defaults, and validates the objectives.
+func checkSummaryOpts(opts *SummaryOpts) (*SummaryOpts, error) {
+	ret := *opts
+	if ret.Objectives == nil {
+		ret.Objectives = DefaultObjectives
+	}
+	if ret.MaxAge <= 0 {
+		ret.MaxAge = DefaultSummaryOpts.MaxAge
+	}
+	if ret.AgeBuckets <= 0 {
+		ret.AgeBuckets = DefaultSummaryOpts.AgeBuckets
+	}
+	if ret.BufCap <= 0 {
+		ret.BufCap = DefaultSummaryOpts.BufCap
+	}
+
+	for q, e := range ret.Objectives {
+		if q <= 0 || q >= 1 {
+			return &ret, fmt.Errorf("quantile %v should be in (0, 1)", q)
+		}
+		if e <= 0 || e >= 1 {
+			return &ret, fmt.Errorf("error %v of quantile %v should be in (0, 1)", e, q)
+		}
+	}
+	return &ret, nil
+}
+
+// newSummaryDesc returns the Desc with the error of the opts recorded.
+func newSummaryDesc(fqName, help string, step uint32, labelKeys []string, opts *SummaryOpts) (*Desc, *SummaryOpts) {
+	desc := NewDesc(fqName, help, step, labelKeys)
+	checked, err := checkSummaryOpts(opts)
+	if err != nil && desc.err == nil {
+		desc.err = fmt.Errorf("%s: %v", fqName, err)
+	}
+	return desc, checked
+}
+
+// quantileName formats the quantile as popMetricWithPer does, more digits are kept only if
+// two are not enough, e.g. 0.999 is named as `0.999` rather than `1.00`.
+func quantileName(fqName string, q float64) string {
+	if math.Abs(q*100-math.Round(q*100)) < 1e-9 {
+		return fmt.Sprintf("%s.%.2f", fqName, q)
+	}
+	return fmt.Sprintf("%s.%s", fqName, strconv.FormatFloat(q, 'f', -1, 64))
+}
+
+type summary struct {
+	*Desc
+	touched
+
+	opts      *SummaryOpts
+	targets   []quantileTarget
+	quantiles []float64
+
+	mtx           sync.Mutex
+	buf           []float64
+	streams       []*targetedStream
+	headIdx       int
+	headExpiredAt time.Time
+	sum           float64
+	count         uint64
+
+	labels   map[string]string
+	interval time.Duration
+}
+
+func newSummary(desc *Desc, opts *SummaryOpts, labels map[string]string) *summary {
+	s := &summary{
+		Desc:          desc,
+		opts:          opts,
+		targets:       quantileTargets(opts.Objectives),
+		buf:           make([]float64, 0, opts.BufCap),
+		headExpiredAt: time.Now().Add(opts.MaxAge),
+		labels:        labels,
+	}
+
+	for _, t := range s.targets {
+		s.quantiles = append(s.quantiles, t.quantile)
+	}
+	for i := 0; i < opts.AgeBuckets; i++ {
+		s.streams = append(s.streams, newTargetedStream(s.targets))
+	}
+	return s
+}
+
+// SummaryVec is a Collector that bundles a set of Summaries which have the same Desc but different label values.
+// It is safe for concurrent use.
+type SummaryVec struct {
+	*metricVec
+
+	opts *SummaryOpts
+}
+
+// Observe adds the observation to the buffer, which is flushed into the streams once it is full.
+// NaN and ±Inf are ignored, they can not be ordered in the streams and would poison the sum.
+func (s *summary) Observe(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+
+	s.mtx.Lock()
+	s.buf = append(s.buf, v)
+	s.sum += v
+	s.count++
+	if len(s.buf) >= s.opts.BufCap {
+		s.flush(time.Now())
+	}
+	s.mtx.Unlock()
+	s.touch()
+}
+
+// rotate resets the head stream and makes the next one the head when it expires, s.mtx must be held.
+// Every stream is reset once per MaxAge, and the head is the one reset longest ago.
+func (s *summary) rotate(now time.Time) {
+	width := s.opts.MaxAge / time.Duration(s.opts.AgeBuckets)
+	for !now.Before(s.headExpiredAt) {
+		s.streams[s.headIdx].reset()
+		s.headIdx = (s.headIdx + 1) % len(s.streams)
+		s.headExpiredAt = s.headExpiredAt.Add(width)
+	}
+}
+
+// flush inserts the buffered observations into all the streams, s.mtx must be held.
+func (s *summary) flush(now time.Time) {
+	s.rotate(now)
+	if len(s.buf) == 0 {
+		return
+	}
+
+	sort.Float64s(s.buf)
+	for _, stream := range s.streams {
+		stream.insert(s.buf)
+	}
+	s.buf = s.buf[:0]
+}
+
+func (s *summary) popMetric(desc *Desc, name string, value interface{}, vt ValueType) Metric {
+	return Metric{
+		Endpoint:  s.labels["endpoint"],
+		Metric:    name,
+		Step:      desc.step,
+		Value:     value,
+		Type:      vt,
+		Labels:    s.labels,
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// collect reports the quantiles of the head stream, which holds the observations of the last
+// MaxAge-MaxAge/AgeBuckets to MaxAge, and the cumulative sum and count of all the observations.
+func (s *summary) collect(desc *Desc, ch chan<- Metric) {
+	s.mtx.Lock()
+	s.flush(time.Now())
+	head := s.streams[s.headIdx]
+	values := make([]float64, len(s.quantiles))
+	for i, q := range s.quantiles {
+		values[i] = head.query(q)
+	}
+	sum, count := s.sum, s.count
+	s.mtx.Unlock()
+
+	for i, q := range s.quantiles {
+		ch <- s.popMetric(desc, quantileName(desc.fqName, q), values[i], GaugeValue)
+	}
+	ch <- s.popMetric(desc, fmt.Sprintf("%s.sum", desc.fqName), sum, CounterValue)
+	ch <- s.popMetric(desc, fmt.Sprintf("%s.count", desc.fqName), count, CounterValue)
+}
+
+// Interval implements aura.Collector.
+func (s *summary) Interval() time.Duration {
+	return s.interval
+}
+
+// Describe implements aura.Collector.
+func (s *summary) Describe(ch chan<- *Desc) {
+	ch <- s.Desc
+}
+
+// Collect implements aura.Collector.
+func (s *summary) Collect(ch chan<- Metric) {
+	s.collect(s.Desc, ch)
+}
+
+// GetMetricWithLabelValues returns the summary with the label values, the curried ones should not be given.
+// An error is returned if the number of label values is wrong or any value is invalid.
+func (sv *SummaryVec) GetMetricWithLabelValues(lvs ...string) (Summary, error) {
+	lvs, err := sv.labelValues(lvs)
+	if err != nil {
+		return nil, err
+	}
+
+	return sv.searchSummary(lvs...), nil
+}
+
+// GetMetricWith returns the summary with the labels, the missing label keys are regarded as empty values.
+// An error is returned if any label key is unknown or curried, or any value is invalid.
+func (sv *SummaryVec) GetMetricWith(labels map[string]string) (Summary, error) {
+	lvs, err := sv.labelValuesFromMap(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return sv.searchSummary(lvs...), nil
+}
+
+// WithLabelValues works as GetMetricWithLabelValues but panics if an error occurs.
+func (sv *SummaryVec) WithLabelValues(lvs ...string) Summary {
+	m, err := sv.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// With works as GetMetricWith but panics if an error occurs.
+func (sv *SummaryVec) With(labels map[string]string) Summary {
+	m, err := sv.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (sv *SummaryVec) searchSummary(lvs ...string) Summary {
+	return sv.child(lvs).(*summary)
+}
+
+// CurryWith returns a SummaryVec with the labels bound, the returned one shares the summaries with sv.
+// It returns an error if any label key is unknown or has been curried.
+func (sv *SummaryVec) CurryWith(labels map[string]string) (*SummaryVec, error) {
+	v, err := sv.curryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &SummaryVec{metricVec: v, opts: sv.opts}, nil
+}
+
+// MustCurryWith is like CurryWith but panics if an error occurs.
+func (sv *SummaryVec) MustCurryWith(labels map[string]string) *SummaryVec {
+	curried, err := sv.CurryWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return curried
+}
+
+func NewSummary(fqName, help string, step uint32, interval time.Duration, opts *SummaryOpts) Summary {
+	if opts == nil {
+		opts = DefaultSummaryOpts
+	}
+
+	desc, opts := newSummaryDesc(fqName, help, step, nil, opts)
+	s := newSummary(desc, opts, map[string]string{})
+	s.interval = interval
+	return s
+}
+
+func NewSummaryVec(fqName, help string, step uint32, interval time.Duration, labelKeys []string, opts *SummaryOpts) *SummaryVec {
+	if opts == nil {
+		opts = DefaultSummaryOpts
+	}
+
+	desc, opts := newSummaryDesc(fqName, help, step, labelKeys, opts)
+	return &SummaryVec{
+		metricVec: newMetricVec("summary", desc, interval, opts.VecOpts, func(labels map[string]string) vecChild {
+			s := newSummary(&Desc{step: step}, opts, labels)
+			s.touched = newTouched(opts.TTL)
+			return s
+		}),
+		opts: opts,
+	}
+}
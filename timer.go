@@ -19,6 +19,16 @@ type Timer interface {
 type TimerOpts struct {
 	HVTypes     []TimerVType
 	Percentiles []float64
+
+	// Sample specifies the sampling strategy of the durations, the DefaultSampleOpts will be used if it is nil.
+	Sample *SampleOpts
+
+	// Aggregate only works for the TimerVec, no aggregated series are reported if it is nil.
+	// The aggregated series have no rates.
+	Aggregate *AggregateOpts
+
+	// VecOpts only works for the TimerVec.
+	VecOpts
 }
 
 var (
@@ -44,39 +54,50 @@ const (
 	TimerVTRateMean TimerVType = "rateMean"
 )
 
+func (v TimerVType) isRate() bool {
+	switch v {
+	case TimerVTRate1, TimerVTRate5, TimerVTRate15, TimerVTRateMean:
+		return true
+	}
+	return false
+}
+
 type timer struct {
 	*Desc
+	touched
 
 	opts     *TimerOpts
 	self     metrics.Timer
+	sample   metrics.Sample
+	sketch   *Sketch
 	labels   map[string]string
 	interval time.Duration
 }
 
+// TimerVec is a Collector that bundles a set of Timers which have the same Desc but different label values.
+// It is safe for concurrent use.
 type TimerVec struct {
-	*Desc
+	*metricVec
 
-	opts     *TimerOpts
-	timers   map[string]*timer
-	interval time.Duration
+	opts *TimerOpts
 }
 
-func (t *timer) switchValues(v TimerVType) interface{} {
+func (t *timer) switchValues(d distribution, v TimerVType) interface{} {
 	switch v {
 	case TimerVTMin:
-		return t.self.Mean()
+		return d.Min()
 	case TimerVTMax:
-		return t.self.Max()
+		return d.Max()
 	case TimerVTMean:
-		return t.self.Mean()
+		return d.Mean()
 	case TimerVTCount:
-		return t.self.Count()
+		return d.Count()
 	case TimerVTStdDev:
-		return t.self.Sum()
+		return d.StdDev()
 	case TimerVTSum:
-		return t.self.StdDev()
+		return d.Sum()
 	case TimerVTVariance:
-		return t.self.Variance()
+		return d.Variance()
 	case TimerVTRate1:
 		return t.self.Rate1()
 	case TimerVTRate5:
@@ -89,36 +110,76 @@ func (t *timer) switchValues(v TimerVType) interface{} {
 	return nil
 }
 
-func (t *timer) popMetricWithHVT(desc *Desc, tvt TimerVType) Metric {
+func (t *timer) popMetricWithHVT(desc *Desc, d distribution, tvt TimerVType) Metric {
 	return Metric{
 		Endpoint:  t.labels["endpoint"],
 		Metric:    fmt.Sprintf("%s.%s", desc.fqName, tvt),
 		Step:      desc.step,
-		Value:     t.switchValues(tvt),
+		Value:     t.switchValues(d, tvt),
 		Type:      GaugeValue,
 		Labels:    t.labels,
 		Timestamp: time.Now().Unix(),
 	}
 }
 
-func (t *timer) popMetricWithPer(desc *Desc, per float64) Metric {
+func (t *timer) popMetricWithPer(desc *Desc, d distribution, per float64) Metric {
 	return Metric{
 		Endpoint:  t.labels["endpoint"],
 		Metric:    fmt.Sprintf("%s.%.2f", desc.fqName, per),
 		Step:      desc.step,
-		Value:     t.self.Percentile(per),
+		Value:     d.Percentile(per),
 		Type:      GaugeValue,
 		Labels:    t.labels,
 		Timestamp: time.Now().Unix(),
 	}
 }
 
+// collect reports the durations of a snapshot of the sample, so they are consistent with each other.
+// The timers bridged from go-metrics have no sample, the snapshot of the timer itself is used instead.
+func (t *timer) collect(desc *Desc, ch chan<- Metric) {
+	var d distribution = t.self.Snapshot()
+	if t.sample != nil {
+		d = snapshotSample(t.sample)
+	}
+	t.report(desc, d, ch)
+}
+
+// report reports the values of the distribution, the rates are skipped if the timer has no meter,
+// e.g. the aggregated one.
+func (t *timer) report(desc *Desc, d distribution, ch chan<- Metric) {
+	for _, tvt := range t.opts.HVTypes {
+		if t.self == nil && tvt.isRate() {
+			continue
+		}
+		ch <- t.popMetricWithHVT(desc, d, tvt)
+	}
+
+	for _, per := range t.opts.Percentiles {
+		ch <- t.popMetricWithPer(desc, d, per)
+	}
+}
+
+func (t *timer) takeSketch() sketchedChild {
+	return sketchedChild{labels: t.labels, sketch: t.sketch.take()}
+}
+
+// stop releases the timer from the ticking of the rates.
+func (t *timer) stop() {
+	t.self.Stop()
+}
+
 func (t *timer) Update(i time.Duration) {
 	t.self.Update(i)
+	if t.sketch != nil {
+		t.sketch.Add(float64(i))
+	}
+	t.touch()
 }
 
 func (t *timer) Time(fn func()) {
-	t.self.Time(fn)
+	start := time.Now()
+	fn()
+	t.Update(time.Since(start))
 }
 
 // Interval implements aura.Collector.
@@ -133,69 +194,93 @@ func (t *timer) Describe(ch chan<- *Desc) {
 
 // Collect implements aura.Collector.
 func (t *timer) Collect(ch chan<- Metric) {
-	for _, hvt := range t.opts.HVTypes {
-		ch <- t.popMetricWithHVT(t.Desc, hvt)
+	t.collect(t.Desc, ch)
+}
+
+// GetMetricWithLabelValues returns the timer with the label values, the curried ones should not be given.
+// An error is returned if the number of label values is wrong or any value is invalid.
+func (tv *TimerVec) GetMetricWithLabelValues(lvs ...string) (Timer, error) {
+	lvs, err := tv.labelValues(lvs)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, per := range t.opts.Percentiles {
-		ch <- t.popMetricWithPer(t.Desc, per)
+	return tv.searchTimer(lvs...), nil
+}
+
+// GetMetricWith returns the timer with the labels, the missing label keys are regarded as empty values.
+// An error is returned if any label key is unknown or curried, or any value is invalid.
+func (tv *TimerVec) GetMetricWith(labels map[string]string) (Timer, error) {
+	lvs, err := tv.labelValuesFromMap(labels)
+	if err != nil {
+		return nil, err
 	}
+
+	return tv.searchTimer(lvs...), nil
 }
 
+// WithLabelValues works as GetMetricWithLabelValues but panics if an error occurs.
 func (tv *TimerVec) WithLabelValues(lvs ...string) Timer {
-	if len(tv.Desc.labelKeys) != len(lvs) {
-		panic(fmt.Sprintf("timer(%s): expected %d label values but go %d",
-			tv.Desc.fqName, len(tv.Desc.labelKeys), len(lvs)),
-		)
+	m, err := tv.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		panic(err)
 	}
-
-	return tv.searchTimer(lvs...)
+	return m
 }
 
+// With works as GetMetricWith but panics if an error occurs.
 func (tv *TimerVec) With(labels map[string]string) Timer {
-	for k := range labels {
-		if !tv.Desc.IsKeyIn(k) {
-			panic(fmt.Sprintf("timer(%s): expected label key: %s, but it dosen't exists", tv.Desc.fqName, k))
-		}
+	m, err := tv.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
 	}
+	return m
+}
 
-	lvs := make([]string, 0)
-	for _, key := range tv.Desc.labelKeys {
-		lvs = append(lvs, labels[key])
+// Collect implements aura.Collector, the aggregated series are reported after the ones of the children.
+func (tv *TimerVec) Collect(ch chan<- Metric) {
+	tv.metricVec.Collect(ch)
+	if tv.opts.Aggregate == nil {
+		return
 	}
 
-	return tv.searchTimer(lvs...)
+	desc := aggregatedDesc(tv.Desc)
+	for _, a := range aggregate(tv.Desc, tv.opts.Aggregate, tv.takeSketches()) {
+		t := &timer{labels: a.labels, opts: tv.opts}
+		t.report(desc, sketchDistribution{a.sketch}, ch)
+	}
 }
 
 func (tv *TimerVec) searchTimer(lvs ...string) Timer {
-	lbp := makeLabelPairs(tv.Desc.fqName, tv.Desc.labelKeys, lvs)
-	lbm := makeLabelMap(tv.Desc.labelKeys, lvs)
-
-	_, ok := tv.timers[lbp]
-	if !ok {
-		tv.timers[lbp] = &timer{self: metrics.NewTimer(), labels: lbm, opts: tv.opts}
-	}
-
-	return tv.timers[lbp]
+	return tv.child(lvs).(*timer)
 }
 
-func (tv *TimerVec) Describe(ch chan<- *Desc) {
-	ch <- tv.Desc
+// CurryWith returns a TimerVec with the labels bound, the returned one shares the timers with tv.
+// It returns an error if any label key is unknown or has been curried.
+func (tv *TimerVec) CurryWith(labels map[string]string) (*TimerVec, error) {
+	mv, err := tv.curryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &TimerVec{metricVec: mv, opts: tv.opts}, nil
 }
 
-func (tv *TimerVec) Interval() time.Duration {
-	return tv.interval
+// MustCurryWith is like CurryWith but panics if an error occurs.
+func (tv *TimerVec) MustCurryWith(labels map[string]string) *TimerVec {
+	curried, err := tv.CurryWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return curried
 }
 
-func (tv *TimerVec) Collect(ch chan<- Metric) {
-	for _, v := range tv.timers {
-		for _, hvt := range v.opts.HVTypes {
-			ch <- v.popMetricWithHVT(tv.Desc, hvt)
-		}
-
-		for _, per := range v.opts.Percentiles {
-			ch <- v.popMetricWithPer(tv.Desc, per)
-		}
+// newTimer returns the timer with its own sample, the rates are still measured by a go-metrics meter.
+func newTimer(opts *TimerOpts) *timer {
+	sample := newSample(opts.Sample)
+	return &timer{
+		self:   metrics.NewCustomTimer(metrics.NewHistogram(sample), metrics.NewMeter()),
+		sample: sample,
+		opts:   opts,
 	}
 }
 
@@ -204,13 +289,11 @@ func NewTimer(fqName, help string, step uint32, interval time.Duration, opts *Ti
 		opts = DefaultTimerOpts
 	}
 
-	return &timer{
-		Desc:     NewDesc(fqName, help, step, nil),
-		self:     metrics.NewTimer(),
-		labels:   map[string]string{},
-		interval: interval,
-		opts:     opts,
-	}
+	t := newTimer(opts)
+	t.Desc = NewDesc(fqName, help, step, nil)
+	t.labels = map[string]string{}
+	t.interval = interval
+	return t
 }
 
 func NewTimerVec(fqName, help string, step uint32, interval time.Duration, labelKeys []string, opts *TimerOpts) *TimerVec {
@@ -218,10 +301,16 @@ func NewTimerVec(fqName, help string, step uint32, interval time.Duration, label
 		opts = DefaultTimerOpts
 	}
 
+	desc := NewDesc(fqName, help, step, labelKeys)
+	checkAggregateOpts(desc, opts.Aggregate)
 	return &TimerVec{
-		Desc:     NewDesc(fqName, help, step, labelKeys),
-		timers:   map[string]*timer{},
-		interval: interval,
-		opts:     opts,
+		metricVec: newMetricVec("timer", desc, interval, opts.VecOpts, func(labels map[string]string) vecChild {
+			t := newTimer(opts)
+			t.labels = labels
+			t.sketch = opts.Aggregate.newSketch()
+			t.touched = newTouched(opts.TTL)
+			return t
+		}),
+		opts: opts,
 	}
 }
@@ -0,0 +1,173 @@
+package aura
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// UpDownCounter counts the values which go both up and down, e.g. the number of in-flight requests.
+// Its current value is reported as a gauge.
+type UpDownCounter interface {
+	Collector
+
+	Inc(int64)
+	Dec(int64)
+	Value() int64
+}
+
+// UpDownCounterOpts specifies the options of an UpDownCounterVec.
+type UpDownCounterOpts struct {
+	VecOpts
+}
+
+// DefaultUpDownCounterOpts holds the UpDownCounterOpts by default case.
+var DefaultUpDownCounterOpts = &UpDownCounterOpts{}
+
+type upDownCounter struct {
+	*Desc
+	touched
+
+	value    int64
+	labels   map[string]string
+	interval time.Duration
+}
+
+// UpDownCounterVec is a Collector that bundles a set of UpDownCounters which have the same Desc
+// but different label values. It is safe for concurrent use.
+type UpDownCounterVec struct {
+	*metricVec
+}
+
+func (c *upDownCounter) popMetric(desc *Desc) Metric {
+	return Metric{
+		Endpoint:  c.labels["endpoint"],
+		Metric:    desc.fqName,
+		Step:      desc.step,
+		Value:     atomic.LoadInt64(&c.value),
+		Type:      GaugeValue,
+		Labels:    c.labels,
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+func (c *upDownCounter) collect(desc *Desc, ch chan<- Metric) {
+	ch <- c.popMetric(desc)
+}
+
+// Inc increases the counter.
+func (c *upDownCounter) Inc(i int64) {
+	atomic.AddInt64(&c.value, i)
+	c.touch()
+}
+
+// Dec decreases the counter.
+func (c *upDownCounter) Dec(i int64) {
+	atomic.AddInt64(&c.value, -i)
+	c.touch()
+}
+
+// Value returns the current value of the counter.
+func (c *upDownCounter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+// Interval implements aura.Collector.
+func (c *upDownCounter) Interval() time.Duration {
+	return c.interval
+}
+
+// Describe implements aura.Collector.
+func (c *upDownCounter) Describe(ch chan<- *Desc) {
+	ch <- c.Desc
+}
+
+// Collect implements aura.Collector.
+func (c *upDownCounter) Collect(ch chan<- Metric) {
+	c.collect(c.Desc, ch)
+}
+
+// GetMetricWithLabelValues returns the counter with the label values, the curried ones should not be given.
+// An error is returned if the number of label values is wrong or any value is invalid.
+func (cv *UpDownCounterVec) GetMetricWithLabelValues(lvs ...string) (UpDownCounter, error) {
+	lvs, err := cv.labelValues(lvs)
+	if err != nil {
+		return nil, err
+	}
+
+	return cv.searchUpDownCounter(lvs...), nil
+}
+
+// GetMetricWith returns the counter with the labels, the missing label keys are regarded as empty values.
+// An error is returned if any label key is unknown or curried, or any value is invalid.
+func (cv *UpDownCounterVec) GetMetricWith(labels map[string]string) (UpDownCounter, error) {
+	lvs, err := cv.labelValuesFromMap(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return cv.searchUpDownCounter(lvs...), nil
+}
+
+// WithLabelValues works as GetMetricWithLabelValues but panics if an error occurs.
+func (cv *UpDownCounterVec) WithLabelValues(lvs ...string) UpDownCounter {
+	m, err := cv.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// With works as GetMetricWith but panics if an error occurs.
+func (cv *UpDownCounterVec) With(labels map[string]string) UpDownCounter {
+	m, err := cv.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (cv *UpDownCounterVec) searchUpDownCounter(lvs ...string) UpDownCounter {
+	return cv.child(lvs).(*upDownCounter)
+}
+
+// CurryWith returns an UpDownCounterVec with the labels bound, the returned one shares the counters with cv.
+// It returns an error if any label key is unknown or has been curried.
+func (cv *UpDownCounterVec) CurryWith(labels map[string]string) (*UpDownCounterVec, error) {
+	mv, err := cv.curryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &UpDownCounterVec{metricVec: mv}, nil
+}
+
+// MustCurryWith is like CurryWith but panics if an error occurs.
+func (cv *UpDownCounterVec) MustCurryWith(labels map[string]string) *UpDownCounterVec {
+	curried, err := cv.CurryWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return curried
+}
+
+func NewUpDownCounter(fqName, help string, step uint32, interval time.Duration) UpDownCounter {
+	return &upDownCounter{
+		Desc:     NewDesc(fqName, help, step, nil),
+		labels:   map[string]string{},
+		interval: interval,
+	}
+}
+
+// NewUpDownCounterVec returns an UpDownCounterVec, the DefaultUpDownCounterOpts will be used if opts is not given.
+func NewUpDownCounterVec(fqName, help string, step uint32, interval time.Duration, labelKeys []string, opts ...*UpDownCounterOpts) *UpDownCounterVec {
+	opt := DefaultUpDownCounterOpts
+	if len(opts) > 0 && opts[0] != nil {
+		opt = opts[0]
+	}
+
+	desc := NewDesc(fqName, help, step, labelKeys)
+	return &UpDownCounterVec{
+		metricVec: newMetricVec("upDownCounter", desc, interval, opt.VecOpts, func(labels map[string]string) vecChild {
+			return &upDownCounter{labels: labels, Desc: &Desc{step: step}, touched: newTouched(opt.TTL)}
+		}),
+	}
+}
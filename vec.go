@@ -0,0 +1,335 @@
+package aura
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// VecOpts specifies the lifecycle of the children of a Vec.
+type VecOpts struct {
+	// TTL drops the children which have received no updates for the duration, zero means never.
+	// A dropped child held by the caller won't be reported any more, look it up again by the label values.
+	TTL time.Duration
+
+	// MaxSeries limits the number of children, the new label values beyond the limit are folded into
+	// the child labeled by OverflowLabelValue. Zero means unlimited.
+	MaxSeries int
+}
+
+// vecChild is a labeled metric held by a Vec.
+type vecChild interface {
+	collect(desc *Desc, ch chan<- Metric)
+	lastUpdate() int64
+}
+
+// release stops the child removed from a Vec if it holds any background resources, e.g. the meter ticking.
+func release(c vecChild) {
+	if s, ok := c.(interface{ stop() }); ok {
+		s.stop()
+	}
+}
+
+// touched records the last time a child is updated for the idle TTL, it costs nothing if the TTL is disabled.
+type touched struct {
+	enabled bool
+	last    int64
+}
+
+func newTouched(ttl time.Duration) touched {
+	return touched{enabled: ttl > 0, last: time.Now().UnixNano()}
+}
+
+func (t *touched) touch() {
+	if t.enabled {
+		atomic.StoreInt64(&t.last, time.Now().UnixNano())
+	}
+}
+
+func (t *touched) lastUpdate() int64 {
+	return atomic.LoadInt64(&t.last)
+}
+
+// vecStore holds the children of a Vec, it is shared by the Vec and the curried ones.
+type vecStore struct {
+	mtx        sync.RWMutex
+	opts       VecOpts
+	overflowed bool
+	children   map[string]vecChild
+	newChild   func(labels map[string]string) vecChild
+}
+
+// curriedLabelValue is a label value bound by CurryWith.
+type curriedLabelValue struct {
+	index int
+	value string
+}
+
+// metricVec is the common part of the Vec types, it maps the label values to the children
+// and it is safe for concurrent use. The existing children are looked up with the read lock,
+// so the hot paths don't contend with each other.
+type metricVec struct {
+	*Desc
+	*vecStore
+
+	kind     string
+	curry    []curriedLabelValue
+	interval time.Duration
+}
+
+func newMetricVec(kind string, desc *Desc, interval time.Duration, opts VecOpts, newChild func(labels map[string]string) vecChild) *metricVec {
+	return &metricVec{
+		Desc: desc,
+		vecStore: &vecStore{
+			opts:     opts,
+			children: map[string]vecChild{},
+			newChild: newChild,
+		},
+		kind:     kind,
+		interval: interval,
+	}
+}
+
+// labelValues merges the curried label values into the given ones.
+func (v *metricVec) labelValues(lvs []string) ([]string, error) {
+	if len(lvs)+len(v.curry) != len(v.Desc.labelKeys) {
+		return nil, fmt.Errorf("%s(%s): expected %d label values but got %d",
+			v.kind, v.Desc.fqName, len(v.Desc.labelKeys)-len(v.curry), len(lvs),
+		)
+	}
+	for _, lv := range lvs {
+		if err := checkLabelValue(lv); err != nil {
+			return nil, fmt.Errorf("%s(%s): %v", v.kind, v.Desc.fqName, err)
+		}
+	}
+	if len(v.curry) == 0 {
+		return lvs, nil
+	}
+
+	ret := make([]string, len(v.Desc.labelKeys))
+	curried, idx := 0, 0
+	for i := range ret {
+		if curried < len(v.curry) && v.curry[curried].index == i {
+			ret[i] = v.curry[curried].value
+			curried++
+			continue
+		}
+		ret[i] = lvs[idx]
+		idx++
+	}
+	return ret, nil
+}
+
+// labelValuesFromMap returns all the label values in the order of the label keys,
+// the label keys missing are regarded as empty values.
+func (v *metricVec) labelValuesFromMap(labels map[string]string) ([]string, error) {
+	if err := v.checkLabels(labels); err != nil {
+		return nil, err
+	}
+
+	lvs := make([]string, len(v.Desc.labelKeys))
+	for i, key := range v.Desc.labelKeys {
+		lvs[i] = labels[key]
+	}
+	for _, c := range v.curry {
+		lvs[c.index] = c.value
+	}
+	return lvs, nil
+}
+
+// checkLabels checks the labels given are known, uncurried and valid.
+func (v *metricVec) checkLabels(labels map[string]string) error {
+	for k, lv := range labels {
+		if !v.Desc.IsKeyIn(k) {
+			return fmt.Errorf("%s(%s): unknown label key %s", v.kind, v.Desc.fqName, k)
+		}
+		if v.isCurried(k) {
+			return fmt.Errorf("%s(%s): label key %s has been curried", v.kind, v.Desc.fqName, k)
+		}
+		if err := checkLabelValue(lv); err != nil {
+			return fmt.Errorf("%s(%s): %v", v.kind, v.Desc.fqName, err)
+		}
+	}
+	return nil
+}
+
+func (v *metricVec) isCurried(key string) bool {
+	for _, c := range v.curry {
+		if v.Desc.labelKeys[c.index] == key {
+			return true
+		}
+	}
+	return false
+}
+
+// curryWith returns a view of the Vec with the labels bound, the children are shared with the Vec.
+func (v *metricVec) curryWith(labels map[string]string) (*metricVec, error) {
+	if err := v.checkLabels(labels); err != nil {
+		return nil, err
+	}
+
+	curry := make([]curriedLabelValue, 0, len(v.curry)+len(labels))
+	for i, key := range v.Desc.labelKeys {
+		if value, ok := labels[key]; ok {
+			curry = append(curry, curriedLabelValue{index: i, value: value})
+			continue
+		}
+		for _, c := range v.curry {
+			if c.index == i {
+				curry = append(curry, c)
+			}
+		}
+	}
+
+	curried := *v
+	curried.curry = curry
+	return &curried, nil
+}
+
+// child returns the child with all the label values, it will be created if not exists.
+func (v *metricVec) child(lvs []string) vecChild {
+	lbp := makeLabelPairs(v.Desc.fqName, v.Desc.labelKeys, lvs)
+
+	v.mtx.RLock()
+	c, ok := v.children[lbp]
+	v.mtx.RUnlock()
+	if ok {
+		return c
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	// the child may have been created while waiting for the lock.
+	if c, ok := v.children[lbp]; ok {
+		return c
+	}
+
+	if v.opts.MaxSeries > 0 && len(v.children) >= v.opts.MaxSeries {
+		if !v.overflowed {
+			v.overflowed = true
+			log.Printf("aura: %s exceeds the limit of %d series, new label values are folded into the %s series",
+				v.Desc.fqName, v.opts.MaxSeries, OverflowLabelValue,
+			)
+		}
+
+		lvs = overflowLabelValues(v.Desc.labelKeys, lvs)
+		lbp = makeLabelPairs(v.Desc.fqName, v.Desc.labelKeys, lvs)
+		if c, ok := v.children[lbp]; ok {
+			return c
+		}
+	}
+
+	c = v.newChild(makeLabelMap(v.Desc.labelKeys, lvs))
+	v.children[lbp] = c
+	return c
+}
+
+// DeleteLabelValues deletes the child with the label values, returns true if it was deleted.
+// The curried label values should not be given. The metric held by the caller is still usable
+// but it won't be reported any more, and the moving average rates of meters and timers stop updating.
+func (v *metricVec) DeleteLabelValues(lvs ...string) bool {
+	lvs, err := v.labelValues(lvs)
+	if err != nil {
+		return false
+	}
+
+	lbp := makeLabelPairs(v.Desc.fqName, v.Desc.labelKeys, lvs)
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	c, ok := v.children[lbp]
+	if !ok {
+		return false
+	}
+	delete(v.children, lbp)
+	release(c)
+	return true
+}
+
+// Delete deletes the child with the labels, returns true if it was deleted.
+// All the label keys of the Vec except the curried ones must be given.
+func (v *metricVec) Delete(labels map[string]string) bool {
+	if len(labels)+len(v.curry) != len(v.Desc.labelKeys) {
+		return false
+	}
+
+	lvs := make([]string, 0, len(labels))
+	for _, key := range v.Desc.labelKeys {
+		if v.isCurried(key) {
+			continue
+		}
+		lv, ok := labels[key]
+		if !ok {
+			return false
+		}
+		lvs = append(lvs, lv)
+	}
+	return v.DeleteLabelValues(lvs...)
+}
+
+// Reset deletes all the children, including the ones of the curried Vecs.
+func (v *metricVec) Reset() {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	for _, c := range v.children {
+		release(c)
+	}
+	v.children = map[string]vecChild{}
+	v.overflowed = false
+}
+
+// expire drops the children which have been idle for longer than the TTL.
+func (v *metricVec) expire() {
+	if v.opts.TTL <= 0 {
+		return
+	}
+
+	deadline := time.Now().Add(-v.opts.TTL).UnixNano()
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	for lbp, c := range v.children {
+		if c.lastUpdate() < deadline {
+			delete(v.children, lbp)
+			release(c)
+		}
+	}
+}
+
+// Interval implements aura.Collector.
+func (v *metricVec) Interval() time.Duration {
+	return v.interval
+}
+
+// Describe implements aura.Collector.
+func (v *metricVec) Describe(ch chan<- *Desc) {
+	ch <- v.Desc
+}
+
+// Collect implements aura.Collector. A curried Vec collects all the children of the Vec.
+func (v *metricVec) Collect(ch chan<- Metric) {
+	v.expire()
+
+	// the children are collected without holding the lock, since sending to the channel may block.
+	for _, c := range v.snapshot() {
+		c.collect(v.Desc, ch)
+	}
+}
+
+// snapshot returns all the children at the moment.
+func (v *metricVec) snapshot() []vecChild {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	children := make([]vecChild, 0, len(v.children))
+	for _, c := range v.children {
+		children = append(children, c)
+	}
+	return children
+}